@@ -0,0 +1,201 @@
+package zerver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+)
+
+type (
+	// httpHandler adapt a net/http handler to Handler, it process all methods
+	httpHandler struct {
+		handler http.Handler
+	}
+
+	// httpMiddleware adapt a net/http middleware to Filter, the middleware is
+	// created only once, the next handler of it continue the filter chain
+	httpMiddleware struct {
+		handler http.Handler
+	}
+
+	// httpChain is the filter chain state passed to next handler of middleware
+	// through request context
+	httpChain struct {
+		req   Request
+		resp  Response
+		chain FilterChain
+		r     *http.Request
+		w     http.ResponseWriter
+	}
+
+	// responseWriter adapt Response to http.ResponseWriter, it write to the
+	// current writer of response, status is shared with response, so it will
+	// be written only once
+	responseWriter struct {
+		resp *response
+		w    http.ResponseWriter
+	}
+
+	contextKey int
+)
+
+const (
+	_CTX_URLVARS contextKey = iota
+	_CTX_CHAIN
+)
+
+// HTTPHandler convert a net/http handler to Handler, it process all methods,
+// url variables can be accessed through HTTPURLVars. Content type set by server
+// is removed, it should be set by the handler itself.
+//
+// http.Handler and func(http.ResponseWriter, *http.Request) can also be registered
+// to router directly.
+func HTTPHandler(h http.Handler) Handler {
+	return httpHandler{handler: h}
+}
+
+// HTTPMiddleware convert a net/http middleware to Filter, the filter chain will
+// be continued when the middleware call the next handler, if the middleware
+// replace request or response writer, the replaced one will be used by the rest
+// of chain like Request.Wrap/Response.Wrap.
+//
+// func(http.Handler) http.Handler can also be registered to router directly.
+func HTTPMiddleware(mw func(http.Handler) http.Handler) Filter {
+	return httpMiddleware{
+		handler: mw(http.HandlerFunc(continueHTTPChain)),
+	}
+}
+
+// HTTPRequest return the underlying net/http request, request may be a wrapper
+// which embed a Request
+func HTTPRequest(req Request) *http.Request {
+	if r, is := req.(*request); is {
+		return r.request
+	}
+
+	return req.raw().request
+}
+
+// HTTPResponseWriter return a net/http response writer of response, it write
+// to the current writer of response, so wrappers added by Response.Wrap are
+// applied. Response may be a wrapper which embed a Response, methods overridden
+// by the wrapper are not used by the returned writer.
+func HTTPResponseWriter(resp Response) http.ResponseWriter {
+	r, is := resp.(*response)
+	if !is {
+		r = resp.raw()
+	}
+
+	return responseWriter{
+		resp: r,
+		w:    r.ResponseWriter,
+	}
+}
+
+// HTTPURLVars return url variables of a net/http request processed by handler/
+// middleware adapter, nil is returned if not exist
+func HTTPURLVars(r *http.Request) URLVarIndexer {
+	vars, _ := r.Context().Value(_CTX_URLVARS).(URLVarIndexer)
+
+	return vars
+}
+
+// withURLVars add url variables of request to context of net/http request
+func withURLVars(req Request) *http.Request {
+	r := HTTPRequest(req)
+
+	return r.WithContext(context.WithValue(r.Context(), _CTX_URLVARS, req))
+}
+
+func (httpHandler) Init(Environment) error { return nil }
+
+func (httpHandler) Destroy() {}
+
+func (h httpHandler) Handler(string) HandleFunc {
+	return h.serve
+}
+
+func (h httpHandler) serve(req Request, resp Response) {
+	resp.RemoveHeader(HEADER_CONTENTTYPE)
+	h.handler.ServeHTTP(HTTPResponseWriter(resp), withURLVars(req))
+}
+
+func (httpMiddleware) Init(Environment) error { return nil }
+
+func (httpMiddleware) Destroy() {}
+
+func (m httpMiddleware) Filter(req Request, resp Response, chain FilterChain) {
+	c := &httpChain{
+		req:   req,
+		resp:  resp,
+		chain: chain,
+		w:     HTTPResponseWriter(resp),
+	}
+	r := withURLVars(req)
+	c.r = r.WithContext(context.WithValue(r.Context(), _CTX_CHAIN, c))
+
+	m.handler.ServeHTTP(c.w, c.r)
+}
+
+// continueHTTPChain is the next handler of net/http middleware, if middleware
+// replace request or response writer, the replaced one is used until the rest
+// of chain returned, writers wrapped by the rest of chain will be closed, the
+// replaced writer is owned by the middleware
+func continueHTTPChain(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(_CTX_CHAIN).(*httpChain)
+	req, resp := c.req.raw(), c.resp.raw()
+
+	r0 := req.request
+	req.request, req.header = r, r.Header
+
+	w0, needClose0 := resp.ResponseWriter, resp.needClose
+	if w != c.w {
+		resp.ResponseWriter, resp.needClose, resp.header = w, false, w.Header()
+	}
+
+	c.chain(c.req, c.resp)
+
+	req.request, req.header = r0, r0.Header
+	if w != c.w {
+		if resp.needClose && !resp.hijacked {
+			_ = resp.ResponseWriter.(io.Closer).Close()
+		}
+		resp.ResponseWriter, resp.needClose, resp.header = w0, needClose0, w0.Header()
+	}
+}
+
+func (w responseWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w responseWriter) WriteHeader(status int) {
+	if !w.resp.statusWrited {
+		w.resp.status = status
+		w.w.WriteHeader(status) // writer may be wrapped by middleware, mark after written
+		w.resp.statusWrited = true
+	}
+}
+
+func (w responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(w.resp.status)
+
+	return w.w.Write(data)
+}
+
+func (w responseWriter) Flush() {
+	if flusher, is := w.w.(http.Flusher); is {
+		flusher.Flush()
+	}
+}
+
+func (w responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, is := w.w.(http.Hijacker)
+	if !is {
+		return nil, nil, ErrHijack
+	}
+
+	w.resp.hijacked = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,105 @@
+package zerver
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w upperWriter) Write(data []byte) (int, error) {
+	return w.ResponseWriter.Write(bytes.ToUpper(data))
+}
+
+func TestHTTPAdapter(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var middlewareCreated int
+	upper := func(next http.Handler) http.Handler {
+		middlewareCreated++
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "upper")
+			next.ServeHTTP(upperWriter{w}, r)
+		})
+	}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(HEADER_AUTHRIZATION) == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/", upper))
+	tt.Nil(s.Handle("/private", auth))
+	tt.Nil(s.Handle("/user/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CONTENTTYPE, "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("user " + HTTPURLVars(r).URLVar("id")))
+	}))
+	tt.Nil(s.Get("/native/:id", func(req Request, resp Response) {
+		resp.WriteString("native " + req.URLVar("id"))
+	}))
+	tt.Nil(s.Handle("/private/data", http.NotFoundHandler()))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(path string, header http.Header) (*MockWriter, string) {
+		w, body := serveTest(s, GET, path, header, nil)
+		return w, body.String()
+	}
+
+	w, body := serve("/user/abc", nil)
+	tt.Eq(http.StatusCreated, w.Status)
+	tt.Eq("USER ABC", body)
+	tt.Eq("upper", w.Headers.Get("X-Middleware"))
+	tt.Eq("text/plain", w.Headers.Get(HEADER_CONTENTTYPE))
+
+	w, body = serve("/native/abc", nil)
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq("NATIVE ABC", body)
+
+	w, body = serve("/private/data", nil)
+	tt.Eq(http.StatusUnauthorized, w.Status)
+	tt.Eq("UNAUTHORIZED\n", body)
+
+	w, _ = serve("/private/data", http.Header{HEADER_AUTHRIZATION: {"token"}})
+	tt.Eq(http.StatusNotFound, w.Status)
+
+	tt.Eq(1, middlewareCreated)
+}
+
+type (
+	testWrappedRequest struct {
+		Request
+	}
+
+	testWrappedResponse struct {
+		Response
+	}
+)
+
+func TestHTTPAdapterWrapped(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	tt.Nil(s.Get("/wrapped", func(req Request, resp Response) {
+		req, resp = testWrappedRequest{req}, testWrappedResponse{resp}
+
+		w := HTTPResponseWriter(resp)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(HTTPRequest(req).URL.Path))
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	w, body := serveTest(s, GET, "/wrapped", nil, nil)
+	tt.Eq(http.StatusAccepted, w.Status)
+	tt.Eq("/wrapped", body.String())
+}
@@ -0,0 +1,295 @@
+package zerver
+
+import (
+	"encoding"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// binding sources, they are also the struct tag names
+	BIND_PATH   = "path"
+	BIND_QUERY  = "query"
+	BIND_HEADER = "header"
+	BIND_FORM   = "form"
+	BIND_BODY   = "body"
+)
+
+type (
+	// BindError is an error of a field when binding request
+	BindError struct {
+		Field  string `json:"field"`
+		Source string `json:"source"`
+		Value  string `json:"value,omitempty"`
+		Reason string `json:"reason"`
+	}
+
+	// BindErrors is all field errors of binding, it can be sent to client directly
+	BindErrors []BindError
+
+	// bindField is a field should be bound from request
+	bindField struct {
+		index  []int
+		name   string // field name
+		source string
+		key    string // name of url variable, parameter or header
+		layout string // time layout
+	}
+)
+
+var (
+	bindSources = []string{BIND_PATH, BIND_QUERY, BIND_HEADER, BIND_FORM}
+
+	bindFieldsCache = make(map[reflect.Type][]bindField)
+	bindFieldsLock  sync.RWMutex
+
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (e BindError) Error() string {
+	return e.Source + " " + e.Field + ": " + e.Reason
+}
+
+func (es BindErrors) Error() string {
+	s := make([]string, len(es))
+	for i := range es {
+		s[i] = es[i].Error()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// Bind fill struct v from request, v must be a pointer to struct.
+//
+// Request body is decoded to v through resource first if it's not a form, then
+// fields are filled by struct tags: `path:"id"`, `query:"page"`, `header:"X-Token"`
+// and `form:"name"`, empty value is skipped. Field can be primitive type, slice of
+// them, time.Time (layout is specified by tag `layout`, default RFC3339),
+// time.Duration, encoding.TextUnmarshaler, or pointer to them.
+//
+// All field errors are returned as BindErrors, if there is no error, v is
+// validated, see Validate.
+func (req *request) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		log.Panicln("Bind require a pointer to struct")
+	}
+
+	var errs BindErrors
+	if req.hasBody() && !isFormRequest(req) {
+		if err := req.receive(v); err == ErrBodyTooLarge || err == ErrUnsupportedMedia {
+			return err
+		} else if err != nil {
+			errs = append(errs, BindError{
+				Source: BIND_BODY,
+				Reason: err.Error(),
+			})
+
+			return errs
+		}
+	}
+
+	rv = rv.Elem()
+	var query map[string][]string
+	for _, f := range structBindFields(rv.Type()) {
+		var values []string
+		switch f.source {
+		case BIND_PATH:
+			if s := req.URLVar(f.key); s != "" {
+				values = []string{s}
+			}
+		case BIND_QUERY:
+			if query == nil {
+				query = req.URL().Query()
+			}
+			values = query[f.key]
+		case BIND_HEADER:
+			values = req.header[http.CanonicalHeaderKey(f.key)]
+		case BIND_FORM:
+			values = req.Params(f.key)
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setFieldValues(rv.FieldByIndex(f.index), values, f.layout); err != nil {
+			errs = append(errs, BindError{
+				Field:  f.name,
+				Source: f.source,
+				Value:  strings.Join(values, ","),
+				Reason: err.Error(),
+			})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return Validate(v)
+}
+
+func (req *request) hasBody() bool {
+	switch req.method {
+	case GET, HEAD, OPTIONS:
+		return false
+	}
+
+	return req.request.ContentLength != 0 && req.request.Body != nil
+}
+
+func isFormRequest(req Request) bool {
+	typ := req.Header(HEADER_CONTENTTYPE)
+
+	return strings.HasPrefix(typ, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(typ, "multipart/form-data")
+}
+
+// structBindFields parse bind fields of struct type, fields of embedded struct
+// are included
+func structBindFields(t reflect.Type) []bindField {
+	bindFieldsLock.RLock()
+	fields, has := bindFieldsCache[t]
+	bindFieldsLock.RUnlock()
+	if has {
+		return fields
+	}
+
+	fields = parseBindFields(t, nil, fields)
+	bindFieldsLock.Lock()
+	bindFieldsCache[t] = fields
+	bindFieldsLock.Unlock()
+
+	return fields
+}
+
+func parseBindFields(t reflect.Type, index []int, fields []bindField) []bindField {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := make([]int, len(index)+1)
+		copy(fi, index)
+		fi[len(index)] = i
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = parseBindFields(f.Type, fi, fields)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+
+		for _, source := range bindSources {
+			if key := f.Tag.Get(source); key != "" && key != "-" {
+				fields = append(fields, bindField{
+					index:  fi,
+					name:   f.Name,
+					source: source,
+					key:    key,
+					layout: f.Tag.Get("layout"),
+				})
+			}
+		}
+	}
+
+	return fields
+}
+
+// setFieldValues set values to field, if field is not slice, only the first
+// value is used
+func setFieldValues(v reflect.Value, values []string, layout string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 &&
+		!reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		s := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(s.Index(i), value, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+
+		return nil
+	}
+
+	return setFieldValue(v, values[0], layout)
+}
+
+func setFieldValue(v reflect.Value, s string, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.CanAddr() {
+		if u, is := v.Addr().Interface().(encoding.TextUnmarshaler); is && v.Type() != timeType {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Type() {
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+
+		return err
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err == nil {
+			v.SetInt(int64(d))
+		}
+
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return ErrUnsupportedBindType
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return ErrUnsupportedBindType
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package zerver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/ygo/resource"
+)
+
+type testPage struct {
+	Page int    `query:"page"`
+	Sort string `query:"sort"`
+}
+
+type testBind struct {
+	testPage
+
+	ID      int64         `path:"id"`
+	Token   string        `header:"X-Token"`
+	Tags    []string      `query:"tag"`
+	Since   time.Time     `query:"since" layout:"2006-01-02"`
+	Timeout time.Duration `query:"timeout"`
+	Limit   *uint         `query:"limit"`
+	Name    string        `json:"name"`
+}
+
+func TestBind(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+
+	var (
+		v   testBind
+		err error
+	)
+	tt.Nil(s.Handle("/users/:id", MapHandler{
+		PUT: func(req Request, resp Response) {
+			v = testBind{}
+			err = req.Bind(&v)
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(path, body string) {
+		header := http.Header{"X-Token": {"abc"}, HEADER_CONTENTTYPE: {"application/json"}}
+		serveTest(s, PUT, path, header, strings.NewReader(body))
+	}
+
+	serve("/users/12?page=2&tag=a&tag=b&since=2015-06-01&timeout=3s&limit=5", `{"name":"zerver"}`)
+	tt.Nil(err)
+	tt.Eq(int64(12), v.ID)
+	tt.Eq("abc", v.Token)
+	tt.Eq(2, v.Page)
+	tt.DeepEq([]string{"a", "b"}, v.Tags)
+	tt.Eq(time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC), v.Since)
+	tt.Eq(3*time.Second, v.Timeout)
+	tt.NotNil(v.Limit)
+	tt.Eq(uint(5), *v.Limit)
+	tt.Eq("zerver", v.Name)
+
+	serve("/users/abc?page=x&limit=-1", "")
+	errs, is := err.(BindErrors)
+	tt.True(is)
+	tt.Eq(3, len(errs))
+	tt.Eq("Page", errs[0].Field)
+	tt.Eq(BIND_QUERY, errs[0].Source)
+	tt.Eq("ID", errs[1].Field)
+	tt.Eq(BIND_PATH, errs[1].Source)
+	tt.Eq("abc", errs[1].Value)
+	tt.Eq("Limit", errs[2].Field)
+
+	serve("/users/1", "{")
+	errs, _ = err.(BindErrors)
+	tt.Eq(1, len(errs))
+	tt.Eq(BIND_BODY, errs[0].Source)
+}
+
+func TestReceiveContentType(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	s.ResMaster.Use("application/vnd.zerver", resource.JSON{})
+
+	var v struct {
+		Name string `json:"name"`
+	}
+	tt.Nil(s.Handle("/", MapHandler{
+		POST: func(req Request, resp Response) {
+			v.Name = ""
+			if err := req.Receive(&v); err != nil {
+				ReportInvalid(req, resp, err)
+			}
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(contentType, accept string) (int, string) {
+		header := http.Header{HEADER_ACCEPT: {accept}}
+		if contentType != "" {
+			header.Set(HEADER_CONTENTTYPE, contentType)
+		}
+		w, _ := serveTest(s, POST, "/", header, strings.NewReader(`{"name":"zerver"}`))
+		return w.Status, w.Headers.Get(HEADER_CONTENTTYPE)
+	}
+
+	status, typ := serve("application/json; charset=utf-8", "application/vnd.zerver")
+	tt.Eq(http.StatusOK, status)
+	tt.Eq("zerver", v.Name)
+	tt.True(strings.HasPrefix(typ, "application/vnd.zerver"))
+
+	status, _ = serve("application/merge-patch+json", "")
+	tt.Eq(http.StatusOK, status)
+	tt.Eq("zerver", v.Name)
+
+	status, _ = serve("", "")
+	tt.Eq(http.StatusOK, status)
+	tt.Eq("zerver", v.Name)
+
+	status, typ = serve("application/xml", "application/json")
+	tt.Eq(http.StatusUnsupportedMediaType, status)
+	tt.Eq("", v.Name)
+	tt.Eq(CONTENTTYPE_PROBLEM, typ)
+}
+
+func TestUnsupportedMedia(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	var v struct {
+		Name string `json:"name"`
+	}
+	tt.Nil(s.Handle("/receive", MapHandler{
+		POST: ErrorFunc(func(req Request, resp Response) error {
+			return req.Receive(&v)
+		}),
+	}))
+	tt.Nil(s.Handle("/bind", MapHandler{
+		POST: func(req Request, resp Response) {
+			if err := req.Bind(&v); err != nil {
+				ReportInvalid(req, resp, err)
+			}
+		},
+	}))
+	tt.Nil(s.Post("/raw", func(req Request, resp Response) {
+		data, _ := ioutil.ReadAll(req)
+		resp.Write(data)
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(path string) (*MockWriter, string) {
+		header := http.Header{HEADER_CONTENTTYPE: {"application/octet-stream"}}
+		w, body := serveTest(s, POST, path, header, strings.NewReader("data"))
+		return w, body.String()
+	}
+
+	// reported by handler
+	for _, path := range []string{"/receive", "/bind"} {
+		w, body := serve(path)
+		tt.Eq(http.StatusUnsupportedMediaType, w.Status, path)
+		tt.Eq(CONTENTTYPE_PROBLEM, w.Headers.Get(HEADER_CONTENTTYPE), path)
+		tt.True(strings.Contains(body, `"status":415`), path)
+	}
+
+	// not rejected by server, raw body can be read
+	w, body := serve("/raw")
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq("data", body)
+}
@@ -0,0 +1,506 @@
+package zerver
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosiner/gohper/errors"
+)
+
+const (
+	CONTENTTYPE_XML  = "application/xml"
+	CONTENTTYPE_FORM = "application/x-www-form-urlencoded"
+	CONTENTTYPE_CSV  = "text/csv"
+
+	ErrUnsupportedValue = errors.Err("unsupported value type for resource")
+
+	// _XML_ITEM is the element name of slice items without XMLName
+	_XML_ITEM = "item"
+)
+
+type (
+	// XMLResource marshal/unmarshal values as XML. Send(key, value) wrap value in
+	// an element named key, items of slice are encoded as children of it named by
+	// XMLName of item type, default "item", if key is empty, value is sent as root
+	// element.
+	XMLResource struct{}
+
+	// FormResource decode application/x-www-form-urlencoded body into pointer to
+	// struct, url.Values, map[string][]string or map[string]string. Struct fields
+	// are named by tag `form`, default the field name, field types are same as
+	// Request.Bind, field errors are returned as BindErrors.
+	//
+	// Send(key, value) encode struct or map as form, key is ignored for form has
+	// no envelope.
+	FormResource struct{}
+
+	// CSVResource encode slice of structs as CSV, the first row is header which
+	// is named by tag `csv`, default the field name, a single struct is encoded as
+	// one row. It decode CSV into pointer to slice of structs by header.
+	//
+	// Send(key, value) ignore key, only the rows are written.
+	CSVResource struct{}
+
+	// codecField is an exported field of struct encoded by form or csv
+	codecField struct {
+		index  []int
+		name   string
+		key    string
+		layout string
+	}
+
+	codecFieldsKey struct {
+		typ reflect.Type
+		tag string
+	}
+)
+
+var (
+	codecFieldsCache = make(map[codecFieldsKey][]codecField)
+	codecFieldsLock  sync.RWMutex
+
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (XMLResource) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XMLResource) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (XMLResource) Send(w io.Writer, key string, value interface{}) error {
+	enc := xml.NewEncoder(w)
+	if key == "" {
+		return enc.Encode(value)
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: key}}
+	if !isListType(reflect.TypeOf(value)) {
+		return enc.EncodeElement(value, start)
+	}
+
+	// EncodeElement write each item of slice as an element named key
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	items := reflect.Indirect(reflect.ValueOf(value))
+	named := hasXMLName(items.Type().Elem())
+	item := xml.StartElement{Name: xml.Name{Local: _XML_ITEM}}
+	for i := 0; i < items.Len(); i++ {
+		var err error
+		if named {
+			err = enc.Encode(items.Index(i).Interface())
+		} else {
+			err = enc.EncodeElement(items.Index(i).Interface(), item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return err
+	}
+
+	return enc.Flush()
+}
+
+// hasXMLName check whether struct type has XMLName field, pointers are dereferenced
+func hasXMLName(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	_, has := t.FieldByName("XMLName")
+
+	return has
+}
+
+// isListType check whether type is slice or array except []byte, pointers are
+// dereferenced
+func isListType(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+func (XMLResource) Receive(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func (FormResource) Marshal(v interface{}) ([]byte, error) {
+	values, err := formValues(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+func (FormResource) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	return decodeForm(values, v)
+}
+
+func (f FormResource) Send(w io.Writer, _ string, value interface{}) error {
+	data, err := f.Marshal(value)
+	if err == nil {
+		_, err = w.Write(data)
+	}
+
+	return err
+}
+
+func (f FormResource) Receive(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return f.Unmarshal(data, v)
+}
+
+func formValues(v interface{}) (url.Values, error) {
+	switch m := v.(type) {
+	case url.Values:
+		return m, nil
+	case map[string][]string:
+		return url.Values(m), nil
+	case map[string]string:
+		values := make(url.Values, len(m))
+		for k, v := range m {
+			values.Set(k, v)
+		}
+		return values, nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedValue
+	}
+
+	values := make(url.Values)
+	for _, f := range codecFields(rv.Type(), BIND_FORM) {
+		vals, err := formatFieldValues(rv.FieldByIndex(f.index), f.layout)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 0 {
+			values[f.key] = vals
+		}
+	}
+
+	return values, nil
+}
+
+func decodeForm(values url.Values, v interface{}) error {
+	switch m := v.(type) {
+	case *url.Values:
+		*m = values
+		return nil
+	case *map[string][]string:
+		*m = values
+		return nil
+	case *map[string]string:
+		if *m == nil {
+			*m = make(map[string]string, len(values))
+		}
+		for k := range values {
+			(*m)[k] = values.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return ErrUnsupportedValue
+	}
+
+	rv = rv.Elem()
+	var errs BindErrors
+	for _, f := range codecFields(rv.Type(), BIND_FORM) {
+		vals := values[f.key]
+		if len(vals) == 0 {
+			continue
+		}
+		if err := setFieldValues(rv.FieldByIndex(f.index), vals, f.layout); err != nil {
+			errs = append(errs, BindError{
+				Field:  f.name,
+				Source: BIND_FORM,
+				Value:  strings.Join(vals, ","),
+				Reason: err.Error(),
+			})
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (c CSVResource) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := c.Send(&buf, "", v)
+
+	return buf.Bytes(), err
+}
+
+func (c CSVResource) Unmarshal(data []byte, v interface{}) error {
+	return c.Receive(bytes.NewReader(data), v)
+}
+
+func (CSVResource) Send(w io.Writer, _ string, value interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if rv.Kind() == reflect.Struct {
+		s := reflect.MakeSlice(reflect.SliceOf(rv.Type()), 1, 1)
+		s.Index(0).Set(rv)
+		rv = s
+	}
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || !isStructType(rv.Type().Elem()) {
+		return ErrUnsupportedValue
+	}
+
+	fields := codecFields(indirectType(rv.Type().Elem()), "csv")
+	row := make([]string, len(fields))
+	for i, f := range fields {
+		row[i] = f.key
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		elem := reflect.Indirect(rv.Index(i))
+		for j, f := range fields {
+			row[j] = ""
+			if !elem.IsValid() {
+				continue
+			}
+
+			vals, err := formatFieldValues(elem.FieldByIndex(f.index), f.layout)
+			if err != nil {
+				return err
+			}
+			row[j] = strings.Join(vals, ",")
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func (CSVResource) Receive(r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice || !isStructType(rv.Elem().Type().Elem()) {
+		return ErrUnsupportedValue
+	}
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	st := rv.Elem().Type()
+	fields := codecFields(indirectType(st.Elem()), "csv")
+	columns := make([]*codecField, len(rows[0]))
+	for i, key := range rows[0] {
+		for j := range fields {
+			if fields[j].key == key {
+				columns[i] = &fields[j]
+				break
+			}
+		}
+	}
+
+	var errs BindErrors
+	s := reflect.MakeSlice(st, len(rows)-1, len(rows)-1)
+	for i, row := range rows[1:] {
+		elem := s.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			elem.Set(reflect.New(elem.Type().Elem()))
+			elem = elem.Elem()
+		}
+		for j, value := range row {
+			f := columns[j]
+			if f == nil || value == "" {
+				continue
+			}
+			fv, values := elem.FieldByIndex(f.index), []string{value}
+			if t := fv.Type(); t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+				values = strings.Split(value, ",")
+			}
+			if err := setFieldValues(fv, values, f.layout); err != nil {
+				errs = append(errs, BindError{
+					Field:  f.name,
+					Source: BIND_BODY,
+					Value:  value,
+					Reason: "row " + strconv.Itoa(i+1) + ": " + err.Error(),
+				})
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	rv.Elem().Set(s)
+
+	return nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+func isStructType(t reflect.Type) bool {
+	return indirectType(t).Kind() == reflect.Struct && indirectType(t) != timeType
+}
+
+// codecFields parse exported fields of struct, field key is the name of tag,
+// default the field name, "-" means skip, fields of embedded struct are included
+func codecFields(t reflect.Type, tag string) []codecField {
+	key := codecFieldsKey{typ: t, tag: tag}
+	codecFieldsLock.RLock()
+	fields, has := codecFieldsCache[key]
+	codecFieldsLock.RUnlock()
+	if has {
+		return fields
+	}
+
+	fields = parseCodecFields(t, tag, nil, nil)
+	codecFieldsLock.Lock()
+	codecFieldsCache[key] = fields
+	codecFieldsLock.Unlock()
+
+	return fields
+}
+
+func parseCodecFields(t reflect.Type, tag string, index []int, fields []codecField) []codecField {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := make([]int, len(index)+1)
+		copy(fi, index)
+		fi[len(index)] = i
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = parseCodecFields(f.Type, tag, fi, fields)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		key := f.Tag.Get(tag)
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = f.Name
+		}
+		fields = append(fields, codecField{
+			index:  fi,
+			name:   f.Name,
+			key:    key,
+			layout: f.Tag.Get("layout"),
+		})
+	}
+
+	return fields
+}
+
+// formatFieldValues format field to strings, slice is formatted to multiple
+// strings, nil pointer is formatted to nothing
+func formatFieldValues(v reflect.Value, layout string) ([]string, error) {
+	t := v.Type()
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !t.Implements(textMarshalerType) {
+		values := make([]string, v.Len())
+		for i := range values {
+			s, err := formatFieldValue(v.Index(i), layout)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = s
+		}
+		return values, nil
+	}
+
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	s, err := formatFieldValue(v, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{s}, nil
+}
+
+// formatFieldValue is the reverse of setFieldValue
+func formatFieldValue(v reflect.Value, layout string) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Type() {
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return v.Interface().(time.Time).Format(layout), nil
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	if m, is := v.Interface().(encoding.TextMarshaler); is {
+		data, err := m.MarshalText()
+		return string(data), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+
+	return "", ErrUnsupportedBindType
+}
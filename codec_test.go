@@ -0,0 +1,98 @@
+package zerver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+type testCodecUser struct {
+	Name   string    `json:"name" xml:"name" form:"name" csv:"name"`
+	Age    int       `json:"age" xml:"age" form:"age" csv:"age"`
+	Tags   []string  `json:"tags" xml:"tag" form:"tag" csv:"tags"`
+	Joined time.Time `json:"joined" xml:"joined" form:"joined" csv:"joined" layout:"2006-01-02"`
+	Secret string    `json:"-" xml:"-" form:"-" csv:"-"`
+}
+
+func TestCodecs(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	s.ResMaster.Use(CONTENTTYPE_XML, XMLResource{})
+	s.ResMaster.Use(CONTENTTYPE_FORM, FormResource{})
+	s.ResMaster.Use(CONTENTTYPE_CSV, CSVResource{})
+
+	var (
+		u   testCodecUser
+		err error
+	)
+	tt.Nil(s.Handle("/user", MapHandler{
+		POST: func(req Request, resp Response) {
+			u = testCodecUser{}
+			if err = req.Receive(&u); err == nil {
+				resp.Send("user", u)
+			}
+		},
+		GET: func(req Request, resp Response) {
+			resp.Send("users", []testCodecUser{u, {Name: "b", Tags: []string{}}})
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method, contentType, accept, body string) string {
+		header := http.Header{HEADER_CONTENTTYPE: {contentType}, HEADER_ACCEPT: {accept}}
+		_, out := serveTest(s, method, "/user", header, strings.NewReader(body))
+		return out.String()
+	}
+
+	body := serve(POST, CONTENTTYPE_FORM, CONTENTTYPE_XML, "name=a&age=20&tag=x&tag=y&joined=2020-01-02&Secret=s")
+	tt.Nil(err)
+	tt.Eq("", u.Secret)
+	tt.Eq("<user><name>a</name><age>20</age><tag>x</tag><tag>y</tag><joined>2020-01-02T00:00:00Z</joined></user>", body)
+
+	body = serve(POST, CONTENTTYPE_XML, CONTENTTYPE_FORM, body)
+	tt.Nil(err)
+	tt.Eq("age=20&joined=2020-01-02&name=a&tag=x&tag=y", body)
+
+	body = serve(GET, "", CONTENTTYPE_XML, "")
+	tt.Eq("<users><item><name>a</name><age>20</age><tag>x</tag><tag>y</tag><joined>2020-01-02T00:00:00Z</joined></item>"+
+		"<item><name>b</name><age>0</age><joined>0001-01-01T00:00:00Z</joined></item></users>", body)
+
+	body = serve(GET, "", CONTENTTYPE_CSV, "")
+	tt.Eq("name,age,tags,joined\na,20,\"x,y\",2020-01-02\nb,0,,0001-01-01\n", body)
+
+	var users []*testCodecUser
+	tt.Nil(CSVResource{}.Unmarshal([]byte(body), &users))
+	tt.Eq(2, len(users))
+	tt.DeepEq(u, *users[0])
+
+	serve(POST, CONTENTTYPE_FORM, "", "age=abc")
+	tt.Eq(BindErrors{{Field: "Age", Source: BIND_FORM, Value: "abc", Reason: `strconv.ParseInt: parsing "abc": invalid syntax`}}, err)
+}
+
+func TestXMLSendItems(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	type book struct {
+		XMLName xml.Name `xml:"book"`
+		Title   string   `xml:"title"`
+	}
+
+	send := func(value interface{}) string {
+		var buf bytes.Buffer
+		tt.Nil(XMLResource{}.Send(&buf, "list", value))
+		return buf.String()
+	}
+
+	tt.Eq("<list><book><title>a</title></book><book><title>b</title></book></list>",
+		send([]book{{Title: "a"}, {Title: "b"}}))
+	tt.Eq("<list><book><title>a</title></book></list>", send(&[]*book{{Title: "a"}}))
+	tt.Eq("<list><item>1</item><item>2</item></list>", send([2]int{1, 2}))
+	tt.Eq("<list></list>", send([]string{}))
+	tt.Eq("<list>abc</list>", send([]byte("abc")))
+}
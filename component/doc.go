@@ -0,0 +1,8 @@
+// Package component provide common components for zerver, such as redis,
+// template, xsrf and resources.
+//
+// MsgPack resource depends on github.com/vmihailenco/msgpack, it's only built
+// with tag "msgpack", without it, MessagePack requests are answered with 406:
+//
+//	go build -tags msgpack
+package component
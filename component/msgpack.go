@@ -0,0 +1,48 @@
+//go:build msgpack
+// +build msgpack
+
+package component
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	CONTENTTYPE_MSGPACK  = "application/msgpack"
+	CONTENTTYPE_XMSGPACK = "application/x-msgpack"
+)
+
+// MsgPack is a MessagePack resource, struct fields are named by tag `json` so it
+// can share types with JSON. Send(key, value) wrap value as {key: value} like
+// JSON, if key is empty, value is sent directly.
+//
+// It's only built with tag "msgpack" for it depends on github.com/vmihailenco/msgpack.
+//
+//	server.ResMaster.Use(component.CONTENTTYPE_MSGPACK, component.MsgPack{})
+type MsgPack struct{}
+
+func (MsgPack) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(v)
+
+	return buf.Bytes(), err
+}
+
+func (MsgPack) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(v)
+}
+
+func (MsgPack) Send(w io.Writer, key string, value interface{}) error {
+	if key != "" {
+		value = map[string]interface{}{key: value}
+	}
+
+	return msgpack.NewEncoder(w).UseJSONTag(true).Encode(value)
+}
+
+func (MsgPack) Receive(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).UseJSONTag(true).Decode(v)
+}
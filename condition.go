@@ -0,0 +1,269 @@
+package zerver
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Condition is a predicate of request evaluated after path matching, if it's
+	// not matched and there is no other handler of the route matched, the Status
+	// will be reported
+	Condition struct {
+		Name   string
+		Match  func(Request) bool
+		Status int
+	}
+
+	// conditionalHandler is a handler only process requests match all conditions
+	conditionalHandler struct {
+		handler Handler
+		conds   []Condition
+	}
+
+	// conditionRoute holds all conditional handlers of a route, handlers with more
+	// conditions will be tried first, handlers with same conditions count will be
+	// tried by registered order, the handler without condition will be tried at last.
+	//
+	// If no handler process the method, 405 is reported, if no handler matched, and all
+	// handlers failed at conditions with same status, the status is reported,
+	// otherwise 404. 404 is processed by server's not found fallback, other status
+	// is sent as problem details.
+	conditionRoute struct {
+		handlers []conditionalHandler
+		handle   HandleFunc
+	}
+)
+
+// When create a conditional handler, it can be registered to same route with other
+// handlers which have different conditions
+func When(handler interface{}, conds ...Condition) Handler {
+	if m, is := handler.(metaHandler); is && m.handler != nil {
+		m.handler = When(m.handler, conds...) // keep metadata outside
+		return m
+	}
+
+	h := convertHandler(handler)
+	if h == nil {
+		log.Panicln("Not a Handler")
+	}
+
+	return conditionalHandler{
+		handler: h,
+		conds:   conds,
+	}
+}
+
+// HeaderIs create a condition match header value, if value is empty, only check
+// the existence of header
+func HeaderIs(name, value string) Condition {
+	return Condition{
+		Name: "header " + name + "=" + value,
+		Match: func(req Request) bool {
+			v := req.Header(name)
+			if value == "" {
+				return v != ""
+			}
+
+			return v == value
+		},
+		Status: http.StatusNotFound,
+	}
+}
+
+// QueryIs create a condition match url query value, if value is empty, only check
+// the existence of query parameter
+func QueryIs(name, value string) Condition {
+	return Condition{
+		Name: "query " + name + "=" + value,
+		Match: func(req Request) bool {
+			values, has := req.URL().Query()[name]
+			if value == "" {
+				return has
+			}
+
+			return has && values[0] == value
+		},
+		Status: http.StatusNotFound,
+	}
+}
+
+// ContentTypeIs create a condition match media type of request content type, such
+// as "application/json", "multipart/*", parameters of content type is ignored,
+// 415(unsupported media type) is reported if not matched
+func ContentTypeIs(types ...string) Condition {
+	lowerTypes := make([]string, len(types))
+	for i := range types {
+		lowerTypes[i] = strings.ToLower(types[i])
+	}
+	types = lowerTypes
+
+	return Condition{
+		Name: "content-type " + strings.Join(types, ","),
+		Match: func(req Request) bool {
+			typ := req.Header(HEADER_CONTENTTYPE)
+			if i := strings.IndexByte(typ, ';'); i >= 0 {
+				typ = typ[:i]
+			}
+			typ = strings.ToLower(strings.TrimSpace(typ))
+
+			for _, t := range types {
+				if t == typ ||
+					(strings.HasSuffix(t, "/*") && strings.HasPrefix(typ, t[:len(t)-1])) {
+					return true
+				}
+			}
+
+			return false
+		},
+		Status: http.StatusUnsupportedMediaType,
+	}
+}
+
+func (h conditionalHandler) Init(env Environment) error {
+	return h.handler.Init(env)
+}
+
+func (h conditionalHandler) Destroy() {
+	h.handler.Destroy()
+}
+
+func (h conditionalHandler) Handler(method string) HandleFunc {
+	return h.handler.Handler(method)
+}
+
+func (h conditionalHandler) match(req Request) (int, bool) {
+	for _, c := range h.conds {
+		if !c.Match(req) {
+			return c.Status, false
+		}
+	}
+
+	return 0, true
+}
+
+func newConditionRoute(h conditionalHandler) *conditionRoute {
+	r := &conditionRoute{
+		handlers: []conditionalHandler{h},
+	}
+	r.handle = r.serve
+
+	return r
+}
+
+// mergeConditionHandler merge handler to exist handler of a route, at least one of them
+// should be conditional handler, and only one handler without condition is allowed.
+// If can't merge, nil is returned.
+func mergeConditionHandler(exist, handler Handler) Handler {
+	route, isRoute := exist.(*conditionRoute)
+	c, isCond := handler.(conditionalHandler)
+	if !isRoute {
+		if !isCond {
+			return nil
+		}
+		route = newConditionRoute(conditionalHandler{handler: exist})
+	}
+	if !isCond {
+		c = conditionalHandler{handler: handler}
+	}
+
+	if !route.add(c) {
+		return nil
+	}
+
+	return route
+}
+
+func (r *conditionRoute) add(h conditionalHandler) bool {
+	handlers := r.handlers
+	if len(h.conds) == 0 {
+		for _, c := range handlers {
+			if len(c.conds) == 0 {
+				return false
+			}
+		}
+	}
+
+	l := len(handlers)
+	handlers = append(handlers, h)
+	for ; l > 0 && len(handlers[l-1].conds) < len(h.conds); l-- {
+		handlers[l] = handlers[l-1]
+	}
+	handlers[l] = h
+	r.handlers = handlers
+
+	return true
+}
+
+func (r *conditionRoute) Init(env Environment) error {
+	var err error
+	for i := 0; i < len(r.handlers) && err == nil; i++ {
+		err = r.handlers[i].Init(env)
+	}
+
+	return err
+}
+
+func (r *conditionRoute) Destroy() {
+	for _, h := range r.handlers {
+		h.Destroy()
+	}
+}
+
+// Handler return nil if there is no handler can process this method
+func (r *conditionRoute) Handler(method string) HandleFunc {
+	for _, h := range r.handlers {
+		if h.handler.Handler(method) != nil {
+			return r.handle
+		}
+	}
+
+	return nil
+}
+
+func (r *conditionRoute) serve(req Request, resp Response) {
+	status, method := 0, req.Method()
+	for _, h := range r.handlers {
+		handle := h.handler.Handler(method)
+		if handle == nil {
+			continue
+		}
+
+		s, matched := h.match(req)
+		if matched {
+			handle(req, resp)
+			return
+		}
+
+		if status == 0 {
+			status = s
+		} else if status != s {
+			status = http.StatusNotFound
+		}
+	}
+
+	if status != http.StatusNotFound {
+		sendProblem(resp, NewProblem(status, ""))
+		return
+	}
+
+	resp.ReportNotFound()
+	if fn := req.Server().fallback(req.raw().request, _FALLBACK_NOTFOUND); fn != nil {
+		fn(req, resp)
+	}
+}
+
+// DescribeRoute list conditions of all handlers
+func (r *conditionRoute) DescribeRoute() string {
+	descs := make([]string, len(r.handlers))
+	for i, h := range r.handlers {
+		names := make([]string, len(h.conds))
+		for j, c := range h.conds {
+			names[j] = c.Name
+		}
+		descs[i] = "[" + strings.Join(names, ", ") + "]"
+	}
+
+	return "conditions: " + strings.Join(descs, " ")
+}
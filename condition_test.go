@@ -0,0 +1,95 @@
+package zerver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func TestConditionRoute(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var handled string
+	handler := func(name string) HandleFunc {
+		return func(Request, Response) {
+			handled = name
+		}
+	}
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/upload", When(MapHandler{POST: handler("json")}, ContentTypeIs("application/json"))))
+	tt.Nil(s.Handle("/upload", When(MapHandler{POST: handler("multipart")}, ContentTypeIs("multipart/*"))))
+	tt.Nil(s.Handle("/upload", When(MapHandler{POST: handler("internal")},
+		ContentTypeIs("application/json"), HeaderIs("X-Internal", ""))))
+	tt.True(s.Handle("/upload", When(MapHandler{POST: handler("dup")})) == nil)
+	tt.True(s.Handle("/upload", MapHandler{POST: handler("dup")}) != nil)
+
+	tt.Nil(s.Get("/events", handler("list")))
+	tt.Nil(s.Handle("/events", When(MapHandler{GET: handler("stream")}, QueryIs("stream", "1"))))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method, path string, header http.Header) int {
+		handled = ""
+		w, _ := serveTest(s, method, path, header, nil)
+		return w.Status
+	}
+
+	serve(POST, "/upload", http.Header{HEADER_CONTENTTYPE: {"application/json; charset=utf-8"}})
+	tt.Eq("json", handled)
+	serve(POST, "/upload", http.Header{HEADER_CONTENTTYPE: {"application/json"}, "X-Internal": {"1"}})
+	tt.Eq("internal", handled)
+	serve(POST, "/upload", http.Header{HEADER_CONTENTTYPE: {"multipart/form-data; boundary=xx"}})
+	tt.Eq("multipart", handled)
+	serve(POST, "/upload", http.Header{HEADER_CONTENTTYPE: {"text/plain"}})
+	tt.Eq("dup", handled)
+
+	serve(GET, "/events", http.Header{})
+	tt.Eq("list", handled)
+	serve(GET, "/events?stream=1", http.Header{})
+	tt.Eq("stream", handled)
+	tt.Eq(http.StatusMethodNotAllowed, serve(POST, "/events", http.Header{}))
+}
+
+func TestConditionStatus(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/upload", When(MapHandler{POST: EmptyHandlerFunc}, ContentTypeIs("application/json"))))
+	tt.Nil(s.Handle("/upload", When(MapHandler{POST: EmptyHandlerFunc}, ContentTypeIs("multipart/*"))))
+	tt.Nil(s.Handle("/user", When(MapHandler{GET: EmptyHandlerFunc}, HeaderIs("X-Internal", "1"))))
+	tt.Nil(s.Handle("/user", When(MapHandler{GET: EmptyHandlerFunc}, ContentTypeIs("application/json"))))
+
+	serve := func(method, path string) (int, string, string) {
+		w, body := serveTest(s, method, path, http.Header{HEADER_CONTENTTYPE: {"text/plain"}}, nil)
+		return w.Status, w.Headers.Get(HEADER_CONTENTTYPE), body.String()
+	}
+
+	status, typ, body := serve(POST, "/upload")
+	tt.Eq(http.StatusUnsupportedMediaType, status)
+	tt.Eq(CONTENTTYPE_PROBLEM, typ)
+	tt.Eq(`{"title":"Unsupported Media Type","status":415}`, body)
+
+	status, _, _ = serve(GET, "/upload")
+	tt.Eq(http.StatusMethodNotAllowed, status)
+
+	// not found is processed by fallback
+	status, _, body = serve(GET, "/user")
+	tt.Eq(http.StatusNotFound, status)
+	tt.Eq("", body)
+
+	s.Fallback.NotFound = ProblemFallback
+	status, typ, body = serve(GET, "/user")
+	tt.Eq(http.StatusNotFound, status)
+	tt.Eq(CONTENTTYPE_PROBLEM, typ)
+	tt.Eq(`{"title":"Not Found","status":404}`, body)
+}
+
+func TestConditionVarNames(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/user/:id", When(MapHandler{GET: EmptyHandlerFunc}, HeaderIs("X-Internal", ""))))
+	tt.Nil(s.Handle("/user/:id", MapHandler{GET: EmptyHandlerFunc}))
+	tt.Eq(ErrConflictVarName, s.Handle("/user/:name", When(MapHandler{POST: EmptyHandlerFunc}, QueryIs("a", ""))))
+}
@@ -0,0 +1,47 @@
+package zerver
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+)
+
+// ContentOption is the option of Response.ServeContent
+type ContentOption struct {
+	// ContentType of content, default detected by extension of name, if failed,
+	// it's sniffed from content
+	ContentType string
+	// Attachment set Content-Disposition to let client download the content as a
+	// file with the base name of name
+	Attachment bool
+}
+
+// ServeContent serve content like http.ServeContent, single range and multiple
+// ranges(multipart/byteranges) are supported for resumable transfers, If-Range,
+// If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since are
+// evaluated by ETag set before and modTime, HEAD request will not write body.
+//
+// The content type set by server for the negotiated resource is not used.
+func (resp *response) ServeContent(req Request, name string, modTime time.Time, content io.ReadSeeker, opt *ContentOption) {
+	var o ContentOption
+	if opt != nil {
+		o = *opt
+	}
+
+	if o.ContentType == "" {
+		resp.RemoveHeader(HEADER_CONTENTTYPE) // let it detected by net/http
+	} else {
+		resp.SetContentType(o.ContentType, nil)
+	}
+	if o.Attachment {
+		params := map[string]string{}
+		if base := path.Base(name); base != "." && base != "/" {
+			params["filename"] = base
+		}
+		resp.SetHeader(HEADER_CONTENTDISPOSITION, mime.FormatMediaType("attachment", params))
+	}
+
+	http.ServeContent(HTTPResponseWriter(resp), HTTPRequest(req), name, modTime, content)
+}
@@ -0,0 +1,67 @@
+package zerver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func TestServeContent(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := "<html>0123456789</html>"
+	tt.Nil(s.Handle("/backup", MapHandler{
+		GET: func(req Request, resp Response) {
+			resp.SetETag(`"v1"`)
+			resp.ServeContent(req, "backup", modTime, strings.NewReader(data), &ContentOption{Attachment: true})
+		},
+		HEAD: func(req Request, resp Response) {
+			resp.ServeContent(req, "backup.txt", modTime, strings.NewReader(data), nil)
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method string, header http.Header) (*MockWriter, string) {
+		w, body := serveTest(s, method, "/backup", header, nil)
+		return w, body.String()
+	}
+
+	w, body := serve(GET, http.Header{})
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq(data, body)
+	tt.Eq("text/html; charset=utf-8", w.Headers.Get(HEADER_CONTENTTYPE)) // sniffed
+	tt.Eq(`attachment; filename=backup`, w.Headers.Get(HEADER_CONTENTDISPOSITION))
+	tt.Eq("bytes", w.Headers.Get("Accept-Ranges"))
+
+	w, body = serve(GET, http.Header{HEADER_RANGE: {"bytes=6-9"}})
+	tt.Eq(http.StatusPartialContent, w.Status)
+	tt.Eq("0123", body)
+	tt.Eq("bytes 6-9/23", w.Headers.Get("Content-Range"))
+
+	w, body = serve(GET, http.Header{HEADER_RANGE: {"bytes=0-1,-2"}})
+	tt.Eq(http.StatusPartialContent, w.Status)
+	tt.True(strings.HasPrefix(w.Headers.Get(HEADER_CONTENTTYPE), "multipart/byteranges"))
+	tt.True(strings.Contains(body, "Content-Range: bytes 21-22/23"))
+
+	w, body = serve(GET, http.Header{HEADER_RANGE: {"bytes=6-9"}, HEADER_IFRANGE: {`"v0"`}})
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq(data, body)
+
+	w, _ = serve(GET, http.Header{HEADER_RANGE: {"bytes=100-"}})
+	tt.Eq(http.StatusRequestedRangeNotSatisfiable, w.Status)
+
+	w, _ = serve(GET, http.Header{HEADER_IFNONEMATCH: {`"v1"`}})
+	tt.Eq(http.StatusNotModified, w.Status)
+
+	w, body = serve(HEAD, http.Header{})
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq("", body)
+	tt.Eq("23", w.Headers.Get(HEADER_CONTENTLENGTH))
+	tt.Eq("text/plain; charset=utf-8", w.Headers.Get(HEADER_CONTENTTYPE))
+	tt.Eq("", w.Headers.Get(HEADER_CONTENTDISPOSITION))
+}
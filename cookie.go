@@ -0,0 +1,269 @@
+package zerver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cosiner/gohper/errors"
+	"github.com/cosiner/ygo/resource"
+)
+
+const (
+	ErrNoCookieKey   = errors.Err("there is no cookie key")
+	ErrInvalidCookie = errors.Err("cookie value is invalid")
+	ErrCookieExpired = errors.Err("cookie value is expired")
+
+	_COOKIE_TIME_SIZE = 8 // expiry time in payload
+)
+
+var _COOKIE_ENCODING = base64.RawURLEncoding
+
+type (
+	// CookieCodec sign or encrypt cookie values, it's used by Response.SetSignedCookie,
+	// Response.SetEncryptedCookie, Request.SignedCookie and Request.EncryptedCookie,
+	// it should be setup before server start.
+	//
+	// Value is marshaled with expiry time, then signed by HMAC-SHA256 or encrypted
+	// by AES-GCM, cookie name is also authenticated.
+	CookieCodec struct {
+		// Keys is secret keys, the first is the newest, it's used to sign and encrypt,
+		// all keys are used to verify and decrypt, so old keys can be kept for
+		// rotation. Keys can be any length, signing and encryption keys are derived
+		// from them.
+		Keys [][]byte
+		// MaxAge is the lifetime of value by seconds if cookie has no MaxAge
+		// and Expires, default 1 day
+		MaxAge int
+		// Codec marshal/unmarshal value, default resource.JSON
+		Codec resource.Resource
+
+		// default attributes of cookie, they are used if not set, Path default "/",
+		// SameSite default Lax, Secure and HttpOnly default true, set Insecure or
+		// ScriptAccess to disable them
+		Path         string
+		Domain       string
+		SameSite     http.SameSite
+		Insecure     bool
+		ScriptAccess bool
+
+		once    sync.Once
+		signs   [][]byte
+		ciphers []cipher.AEAD
+		err     error
+	}
+)
+
+// init derive signing and encryption keys
+func (c *CookieCodec) init() error {
+	c.once.Do(func() {
+		if len(c.Keys) == 0 {
+			c.err = ErrNoCookieKey
+			return
+		}
+		if c.MaxAge == 0 {
+			c.MaxAge = 24 * 60 * 60
+		}
+		if c.Codec == nil {
+			c.Codec = resource.JSON{}
+		}
+
+		for _, key := range c.Keys {
+			c.signs = append(c.signs, deriveKey(key, "zerver-cookie-sign"))
+
+			block, err := aes.NewCipher(deriveKey(key, "zerver-cookie-encrypt"))
+			if err == nil {
+				var aead cipher.AEAD
+				if aead, err = cipher.NewGCM(block); err == nil {
+					c.ciphers = append(c.ciphers, aead)
+					continue
+				}
+			}
+			c.err = err
+			return
+		}
+	})
+
+	return c.err
+}
+
+func deriveKey(key []byte, usage string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(usage))
+
+	return h.Sum(nil)
+}
+
+// Encode marshal value with expiry time, then sign or encrypt it
+func (c *CookieCodec) Encode(name string, value interface{}, expires time.Time, encrypt bool) (string, error) {
+	if err := c.init(); err != nil {
+		return "", err
+	}
+
+	data, err := c.Codec.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	payload := make([]byte, _COOKIE_TIME_SIZE, _COOKIE_TIME_SIZE+len(data))
+	binary.BigEndian.PutUint64(payload, uint64(expires.Unix()))
+	payload = append(payload, data...)
+
+	if encrypt {
+		aead := c.ciphers[0]
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+			return "", err
+		}
+		payload = aead.Seal(nonce, nonce, payload, []byte(name))
+	} else {
+		payload = append(payload, sign(c.signs[0], name, payload)...)
+	}
+
+	return _COOKIE_ENCODING.EncodeToString(payload), nil
+}
+
+// Decode verify or decrypt value, then unmarshal it to ptr, if failed,
+// ErrInvalidCookie is returned, if expired, ErrCookieExpired is returned
+func (c *CookieCodec) Decode(name, value string, ptr interface{}, encrypt bool) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	data, err := _COOKIE_ENCODING.DecodeString(value)
+	if err != nil {
+		return ErrInvalidCookie
+	}
+
+	var payload []byte
+	if encrypt {
+		for _, aead := range c.ciphers {
+			if len(data) < aead.NonceSize() {
+				break
+			}
+			nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+			if payload, err = aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+				break
+			}
+		}
+	} else if sep := len(data) - sha256.Size; sep >= 0 {
+		for _, key := range c.signs {
+			if hmac.Equal(sign(key, name, data[:sep]), data[sep:]) {
+				payload = data[:sep]
+				break
+			}
+		}
+	}
+	if len(payload) < _COOKIE_TIME_SIZE {
+		return ErrInvalidCookie
+	}
+
+	if expires := int64(binary.BigEndian.Uint64(payload)); expires < time.Now().Unix() {
+		return ErrCookieExpired
+	}
+
+	return c.Codec.Unmarshal(payload[_COOKIE_TIME_SIZE:], ptr)
+}
+
+func sign(key []byte, name string, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(data)
+
+	return h.Sum(nil)
+}
+
+// fillCookie fill unset Path, Domain and SameSite of cookie with configured values
+func (c *CookieCodec) fillCookie(cookie *http.Cookie) {
+	if cookie.Path == "" {
+		cookie.Path = c.Path
+	}
+	if cookie.Domain == "" {
+		cookie.Domain = c.Domain
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = c.SameSite
+	}
+}
+
+// setDefaults fill unset attributes of cookie, return expiry time of value
+func (c *CookieCodec) setDefaults(cookie *http.Cookie) time.Time {
+	c.fillCookie(cookie)
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	cookie.Secure = cookie.Secure || !c.Insecure
+	cookie.HttpOnly = cookie.HttpOnly || !c.ScriptAccess
+
+	switch {
+	case cookie.MaxAge > 0:
+		return time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		return cookie.Expires
+	}
+
+	return time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+}
+
+func (resp *response) setCodecCookie(c *http.Cookie, value interface{}, encrypt bool) error {
+	codec := &resp.env.Server().Cookies
+	if err := codec.init(); err != nil {
+		return err
+	}
+
+	cookie := *c
+	expires := codec.setDefaults(&cookie)
+	v, err := codec.Encode(cookie.Name, value, expires, encrypt)
+	if err != nil {
+		return err
+	}
+	cookie.Value = v
+	resp.SetAdvancedCookie(&cookie)
+
+	return nil
+}
+
+// SetSignedCookie sign value by server's CookieCodec, it can be read by client
+// but can't be modified. Value of c is ignored, unset attributes are filled by
+// defaults of CookieCodec.
+func (resp *response) SetSignedCookie(c *http.Cookie, value interface{}) error {
+	return resp.setCodecCookie(c, value, false)
+}
+
+// SetEncryptedCookie encrypt value by server's CookieCodec, it can't be read or
+// modified by client, others are same as SetSignedCookie
+func (resp *response) SetEncryptedCookie(c *http.Cookie, value interface{}) error {
+	return resp.setCodecCookie(c, value, true)
+}
+
+func (req *request) codecCookie(name string, ptr interface{}, encrypt bool) error {
+	c, err := req.request.Cookie(name)
+	if err != nil {
+		return err
+	}
+
+	return req.Server().Cookies.Decode(name, c.Value, ptr, encrypt)
+}
+
+// SignedCookie verify cookie value set by Response.SetSignedCookie, then unmarshal
+// it to ptr, if cookie not exist, http.ErrNoCookie is returned
+func (req *request) SignedCookie(name string, ptr interface{}) error {
+	return req.codecCookie(name, ptr, false)
+}
+
+// EncryptedCookie decrypt cookie value set by Response.SetEncryptedCookie, then
+// unmarshal it to ptr, if cookie not exist, http.ErrNoCookie is returned
+func (req *request) EncryptedCookie(name string, ptr interface{}) error {
+	return req.codecCookie(name, ptr, true)
+}
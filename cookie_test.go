@@ -0,0 +1,98 @@
+package zerver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+type testSession struct {
+	User  string `json:"user"`
+	Roles []string
+}
+
+func TestCookieCodec(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	old := &CookieCodec{Keys: [][]byte{[]byte("old")}}
+	c := &CookieCodec{Keys: [][]byte{[]byte("new"), []byte("old")}}
+	expires := time.Now().Add(time.Hour)
+
+	for _, encrypt := range []bool{false, true} {
+		v, err := old.Encode("session", testSession{User: "zerver"}, expires, encrypt)
+		tt.Nil(err)
+
+		var s testSession
+		tt.Nil(c.Decode("session", v, &s, encrypt)) // rotated key
+		tt.Eq("zerver", s.User)
+		tt.Eq(ErrInvalidCookie, c.Decode("other", v, &s, encrypt))
+
+		v, err = c.Encode("session", testSession{User: "zerver"}, expires, encrypt)
+		tt.Nil(err)
+		tt.Eq(ErrInvalidCookie, old.Decode("session", v, &s, encrypt))
+		tampered := []byte(v)
+		if i := len(v) / 2; tampered[i] == 'A' {
+			tampered[i] = 'B'
+		} else {
+			tampered[i] = 'A'
+		}
+		tt.Eq(ErrInvalidCookie, c.Decode("session", string(tampered), &s, encrypt))
+
+		v, _ = c.Encode("session", testSession{}, time.Now().Add(-time.Second), encrypt)
+		tt.Eq(ErrCookieExpired, c.Decode("session", v, &s, encrypt))
+	}
+
+	v, _ := c.Encode("session", "plain-value", expires, true)
+	tt.False(strings.Contains(v, "plain"))
+	tt.Eq(ErrNoCookieKey, (&CookieCodec{}).Decode("session", v, new(string), true))
+}
+
+func TestSecureCookie(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	s.Cookies.Keys = [][]byte{[]byte("secret")}
+
+	var (
+		sess testSession
+		err  error
+	)
+	tt.Nil(s.Handle("/", MapHandler{
+		POST: func(req Request, resp Response) {
+			resp.SetEncryptedCookie(&http.Cookie{Name: "session", MaxAge: 60}, testSession{User: "zerver"})
+			resp.SetSignedCookie(&http.Cookie{Name: "user"}, "zerver")
+		},
+		GET: func(req Request, resp Response) {
+			sess = testSession{}
+			err = req.EncryptedCookie("session", &sess)
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method string, header http.Header) http.Header {
+		w, _ := serveTest(s, method, "/", header, nil)
+		return w.Headers
+	}
+
+	cookies := (&http.Response{Header: serve(POST, http.Header{})}).Cookies()
+	tt.Eq(2, len(cookies))
+	tt.Eq("session", cookies[0].Name)
+	tt.True(cookies[0].Secure)
+	tt.True(cookies[0].HttpOnly)
+	tt.Eq(http.SameSiteLaxMode, cookies[0].SameSite)
+	tt.Eq("/", cookies[0].Path)
+	tt.Eq(60, cookies[0].MaxAge)
+
+	serve(GET, http.Header{"Cookie": {cookies[0].Name + "=" + cookies[0].Value}})
+	tt.Nil(err)
+	tt.Eq("zerver", sess.User)
+
+	serve(GET, http.Header{"Cookie": {"session=" + cookies[1].Value}})
+	tt.Eq(ErrInvalidCookie, err)
+
+	serve(GET, http.Header{})
+	tt.Eq(http.ErrNoCookie, err)
+}
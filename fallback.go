@@ -0,0 +1,121 @@
+package zerver
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	_FALLBACK_NOTFOUND = iota
+	_FALLBACK_METHODNOTALLOWED
+	_FALLBACK_NOTACCEPTABLE
+)
+
+type (
+	// Fallback handle requests which can't be processed by route handlers, the status
+	// has been reported before handler called, and route filters still be applied.
+	// Response is rendered by the negotiated resource, for not acceptable request,
+	// the default resource is used. Nil handler means only status is reported.
+	Fallback struct {
+		NotFound         HandleFunc
+		MethodNotAllowed HandleFunc
+		NotAcceptable    HandleFunc
+	}
+
+	// fallbackEntry is a fallback for requests of host and path prefix, nil host
+	// match all hosts
+	fallbackEntry struct {
+		host   *HostPattern
+		prefix string
+		Fallback
+	}
+
+	fallbacks []fallbackEntry
+)
+
+// ProblemFallback send status as problem details document, it can be used as
+// handler of Fallback
+func ProblemFallback(req Request, resp Response) {
+	sendProblem(resp, NewProblem(resp.Status(), ""))
+}
+
+func (f *Fallback) handler(kind int) HandleFunc {
+	switch kind {
+	case _FALLBACK_NOTFOUND:
+		return f.NotFound
+	case _FALLBACK_METHODNOTALLOWED:
+		return f.MethodNotAllowed
+	default:
+		return f.NotAcceptable
+	}
+}
+
+// SetFallback set fallback for requests of host and path prefix, such as a route
+// group, empty host match all hosts, host pattern is same as host router such as
+// "*.example.com" or ":tenant.example.com", prefix is matched by path segments.
+// Host specified is more specific, then longer prefix, nil handlers of the most
+// specific fallback fall through to less specific ones, finally Server.Fallback.
+//
+// It should be called before server start.
+func (s *Server) SetFallback(host, prefix string, f Fallback) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	for i := range s.fallbacks {
+		e := &s.fallbacks[i]
+		if e.prefix == prefix && ((e.host == nil && host == "") || (e.host != nil && e.host.String() == host)) {
+			e.Fallback = f
+			return
+		}
+	}
+
+	e := fallbackEntry{prefix: prefix, Fallback: f}
+	if host != "" {
+		e.host = NewHostPattern(host)
+	}
+	s.fallbacks = append(s.fallbacks, e)
+	sort.Stable(s.fallbacks)
+}
+
+func (fs fallbacks) Len() int {
+	return len(fs)
+}
+
+func (fs fallbacks) Less(i, j int) bool {
+	hi, hj := fs[i].host, fs[j].host
+	if (hi != nil) != (hj != nil) {
+		return hi != nil
+	}
+	if hi != nil && hi.Before(hj) != hj.Before(hi) {
+		return hi.Before(hj)
+	}
+
+	return len(fs[i].prefix) > len(fs[j].prefix)
+}
+
+func (fs fallbacks) Swap(i, j int) {
+	fs[i], fs[j] = fs[j], fs[i]
+}
+
+// fallback find the fallback handler for request
+func (s *Server) fallback(request *http.Request, kind int) HandleFunc {
+	if len(s.fallbacks) != 0 {
+		path := request.URL.Path
+		for i := range s.fallbacks {
+			e := &s.fallbacks[i]
+			if e.host != nil {
+				if _, matched := e.host.Match(request.Host); !matched {
+					continue
+				}
+			}
+			if !strings.HasPrefix(path, e.prefix) ||
+				(len(path) > len(e.prefix) && path[len(e.prefix)] != '/') {
+				continue
+			}
+			if h := e.handler(kind); h != nil {
+				return h
+			}
+		}
+	}
+
+	return s.Fallback.handler(kind)
+}
@@ -0,0 +1,71 @@
+package zerver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func TestFallback(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/api/user", MapHandler{GET: EmptyHandlerFunc}))
+	tt.Nil(s.Router.Init(s))
+
+	text := func(body string) HandleFunc {
+		return func(req Request, resp Response) {
+			resp.WriteString(body)
+		}
+	}
+	s.Fallback = Fallback{
+		NotFound:         text("not found"),
+		MethodNotAllowed: text("method not allowed"),
+		NotAcceptable:    ProblemFallback,
+	}
+	s.SetFallback("", "/api", Fallback{NotFound: ProblemFallback})
+	s.SetFallback("admin.example.com", "/", Fallback{NotFound: text("admin")})
+	s.SetFallback("*.example.org", "", Fallback{NotFound: text("org")})
+
+	serve := func(method, host, path, accept string) (*MockWriter, string) {
+		header := http.Header{}
+		if accept != "" {
+			header.Set(HEADER_ACCEPT, accept)
+		}
+		w, body := serveTest(s, method, "http://"+host+path, header, nil)
+		return w, body.String()
+	}
+
+	w, body := serve(GET, "example.com", "/home", "")
+	tt.Eq(http.StatusNotFound, w.Status)
+	tt.Eq("not found", body)
+
+	w, body = serve(GET, "example.com:8080", "/api/book", "")
+	tt.Eq(http.StatusNotFound, w.Status)
+	tt.Eq(CONTENTTYPE_PROBLEM, w.Headers.Get(HEADER_CONTENTTYPE))
+	tt.Eq(`{"title":"Not Found","status":404}`, body)
+
+	_, body = serve(GET, "example.com", "/apis", "")
+	tt.Eq("not found", body)
+
+	_, body = serve(GET, "admin.example.com", "/api/book", "")
+	tt.Eq("admin", body)
+
+	_, body = serve(GET, "ADMIN.Example.com:8080", "/home", "")
+	tt.Eq("admin", body)
+
+	_, body = serve(GET, "www.example.org:8080", "/api/book", "")
+	tt.Eq("org", body)
+
+	_, body = serve(GET, "example.org", "/home", "")
+	tt.Eq("not found", body)
+
+	w, body = serve(POST, "example.com", "/api/user", "")
+	tt.Eq(http.StatusMethodNotAllowed, w.Status)
+	tt.Eq("method not allowed", body)
+
+	w, body = serve(GET, "example.com", "/api/user", "text/html")
+	tt.Eq(http.StatusNotAcceptable, w.Status)
+	tt.Eq(`{"title":"Not Acceptable","status":406}`, body)
+}
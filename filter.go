@@ -2,6 +2,7 @@ package zerver
 
 import (
 	"log"
+	"net/http"
 	"net/url"
 )
 
@@ -59,6 +60,8 @@ func convertFilter(i interface{}) Filter {
 		return FilterFunc(f)
 	case Filter:
 		return f
+	case func(http.Handler) http.Handler:
+		return HTTPMiddleware(f)
 	}
 
 	return nil
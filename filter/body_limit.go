@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/cosiner/gohper/defval"
+	"github.com/cosiner/ygo/log"
+	"github.com/cosiner/zerver"
+)
+
+type (
+	// BodyLimit is the max bytes of request body, it can be attached to route as
+	// metadata to override the limit of BodyLimiter, negative means no limit
+	//
+	//  server.Handle("/upload", zerver.WithMeta(handler, filter.BodyLimit(64<<20)))
+	BodyLimit int64
+
+	// BodyLimiter limit size of request body, request with larger Content-Length is
+	// reported as 413 directly, otherwise request body is wrapped by a limited reader,
+	// reading exceed the limit return zerver.ErrBodyTooLarge, and status is reported
+	// as 413, zerver.ReportInvalid also report the error as 413.
+	BodyLimiter struct {
+		// default limit, default 4M, negative means no limit
+		Limit int64
+		// error message send to client, default "request body too large"
+		Error string
+
+		logger log.Logger
+	}
+
+	limitedBody struct {
+		io.ReadCloser
+		limit    int64
+		remain   int64
+		exceeded bool
+
+		limiter *BodyLimiter
+		req     zerver.Request
+		resp    zerver.Response
+	}
+)
+
+func (l *BodyLimiter) Init(env zerver.Environment) error {
+	if l.Limit == 0 {
+		l.Limit = 4 << 20
+	}
+	defval.String(&l.Error, "request body too large")
+	l.logger = env.Logger().Prefix("[BodyLimit]")
+
+	return nil
+}
+
+func (l *BodyLimiter) Destroy() {}
+
+func (l *BodyLimiter) Filter(req zerver.Request, resp zerver.Response, chain zerver.FilterChain) {
+	limit := l.Limit
+	var bl BodyLimit
+	if req.RouteMeta().Find(&bl) {
+		limit = int64(bl)
+	}
+	if limit < 0 {
+		chain(req, resp)
+		return
+	}
+
+	if zerver.HTTPRequest(req).ContentLength > limit {
+		l.report(req, resp, limit)
+		resp.Send("error", l.Error)
+		return
+	}
+
+	req.Wrap(func(r *http.Request, needClose bool) (*http.Request, bool) {
+		if r.Body == nil {
+			return r, needClose
+		}
+
+		nr := *r
+		nr.Body = &limitedBody{
+			ReadCloser: r.Body,
+			limit:      limit,
+			remain:     limit,
+			limiter:    l,
+			req:        req,
+			resp:       resp,
+		}
+		return &nr, needClose
+	})
+	chain(req, resp)
+}
+
+func (l *BodyLimiter) report(req zerver.Request, resp zerver.Response, limit int64) {
+	resp.ReportRequestEntityTooLarge()
+	l.logger.Warnln(req.RemoteIP(), req.Method(), req.URL().Path, "request body exceed limit", limit)
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, zerver.ErrBodyTooLarge
+	}
+
+	// read at most one byte more than remain to detect exceeding
+	if int64(len(p)) > b.remain+1 {
+		p = p[:b.remain+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remain {
+		b.exceeded = true
+		b.limiter.report(b.req, b.resp, b.limit)
+		n, b.remain = int(b.remain), 0
+
+		return n, zerver.ErrBodyTooLarge
+	}
+	b.remain -= int64(n)
+
+	return n, err
+}
@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/zerver"
+)
+
+func TestBodyLimiter(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var (
+		called bool
+		err    error
+	)
+	read := func(req zerver.Request, resp zerver.Response) {
+		called = true
+
+		var data []byte
+		if data, err = ioutil.ReadAll(req); err == nil {
+			resp.Write(data)
+		}
+	}
+
+	s := newTestServer(&BodyLimiter{Limit: 5})
+	tt.Nil(s.Post("/", read))
+	tt.Nil(s.Handle("/large", zerver.WithMeta(zerver.MapHandler{zerver.POST: read}, BodyLimit(16))))
+	tt.Nil(s.Handle("/unlimited", zerver.WithMeta(zerver.MapHandler{zerver.POST: read}, BodyLimit(-1))))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(path, body string, chunked bool) *httptest.ResponseRecorder {
+		called, err = false, nil
+
+		var r io.Reader = strings.NewReader(body)
+		if chunked {
+			r = struct{ io.Reader }{r} // unknown length
+		}
+		return serveTest(s, nil, zerver.POST, path, nil, r)
+	}
+
+	w := serve("/", "hello", false)
+	tt.True(called)
+	tt.Nil(err)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("hello", w.Body.String())
+
+	// rejected by Content-Length before handler
+	w = serve("/", "hello world", false)
+	tt.False(called)
+	tt.Eq(http.StatusRequestEntityTooLarge, w.Code)
+	tt.Eq(`{"error":"request body too large"}`+"\n", w.Body.String())
+
+	// chunked body is limited when reading
+	w = serve("/", "hello world", true)
+	tt.True(called)
+	tt.Eq(zerver.ErrBodyTooLarge, err)
+	tt.Eq(http.StatusRequestEntityTooLarge, w.Code)
+
+	w = serve("/", "hello", true)
+	tt.Nil(err)
+	tt.Eq("hello", w.Body.String())
+
+	// route metadata override default limit
+	w = serve("/large", "hello world", false)
+	tt.Nil(err)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("hello world", w.Body.String())
+
+	w = serve("/large", strings.Repeat("x", 17), true)
+	tt.Eq(zerver.ErrBodyTooLarge, err)
+	tt.Eq(http.StatusRequestEntityTooLarge, w.Code)
+
+	// negative limit means no limit
+	body := strings.Repeat("x", 1024)
+	w = serve("/unlimited", body, false)
+	tt.Nil(err)
+	tt.Eq(body, w.Body.String())
+
+	w = serve("/unlimited", body, true)
+	tt.Nil(err)
+	tt.Eq(body, w.Body.String())
+}
+
+func TestBodyLimiterNoLimit(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer(&BodyLimiter{Limit: -1})
+	tt.Nil(s.Post("/", func(req zerver.Request, resp zerver.Response) {
+		data, _ := ioutil.ReadAll(req)
+		resp.Write(data)
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	body := strings.Repeat("x", 8<<20)
+	w := serveTest(s, nil, zerver.POST, "/", nil, strings.NewReader(body))
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq(len(body), w.Body.Len())
+}
+
+func TestBodyLimiterMethodOverride(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer(&BodyLimiter{Limit: 5})
+	tt.Nil(s.Put("/", func(req zerver.Request, resp zerver.Response) {
+		data, _ := ioutil.ReadAll(req)
+		resp.WriteString(req.Method() + " ")
+		resp.Write(data)
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	header := make(http.Header)
+	header.Set(zerver.HEADER_METHODOVERRIDE, zerver.PUT)
+	w := serveTest(s, nil, zerver.POST, "/", header, strings.NewReader("hello"))
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("PUT hello", w.Body.String())
+}
@@ -12,59 +12,73 @@ import (
 	"github.com/cosiner/zerver"
 )
 
-type gzipWriter struct {
-	gw *gzip.Writer
+// compressWriter decide whether to compress response when status is written,
+// response already has content encoding such as precompressed file, or status
+// without full body such as 204, 206, 304 will not be compressed
+type compressWriter struct {
+	encoding  string
+	newWriter func(io.Writer) io.WriteCloser
+	cw        io.WriteCloser
 	http.ResponseWriter
 	needClose bool
 }
 
-func (w gzipWriter) Write(data []byte) (int, error) {
-	return w.gw.Write(data)
-}
+func (w *compressWriter) WriteHeader(status int) {
+	header := w.Header()
+	if header.Get(zerver.HEADER_CONTENTENCODING) == "" &&
+		status != http.StatusNoContent &&
+		status != http.StatusPartialContent &&
+		status != http.StatusNotModified {
 
-func (w gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hijacker, is := w.ResponseWriter.(http.Hijacker)
-	if !is {
-		return nil, nil, zerver.ErrHijack
+		header.Set(zerver.HEADER_CONTENTENCODING, w.encoding)
+		header.Del(zerver.HEADER_CONTENTLENGTH)
+		w.cw = w.newWriter(w.ResponseWriter)
 	}
+	header.Add(zerver.HEADER_VARY, zerver.HEADER_ACCEPTENCODING)
 
-	w.gw.Close()
-
-	return hijacker.Hijack()
+	w.ResponseWriter.WriteHeader(status)
 }
 
-func (w gzipWriter) Close() error {
-	err := w.gw.Close()
-	if w.needClose {
-		_ = w.ResponseWriter.(io.Closer).Close()
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.cw == nil {
+		return w.ResponseWriter.Write(data)
 	}
 
-	return err
-}
-
-type flateWriter struct {
-	fw *flate.Writer
-	http.ResponseWriter
-	needClose bool
+	return w.cw.Write(data)
 }
 
-func (w flateWriter) Write(data []byte) (int, error) {
-	return w.fw.Write(data)
+// Flush flush compressed data, then flush response, it's required by streaming
+// response such as server-sent events
+func (w *compressWriter) Flush() {
+	if f, is := w.cw.(interface {
+		Flush() error
+	}); is {
+		f.Flush()
+	}
+	if f, is := w.ResponseWriter.(http.Flusher); is {
+		f.Flush()
+	}
 }
 
-func (w flateWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hijacker, is := w.ResponseWriter.(http.Hijacker)
 	if !is {
 		return nil, nil, zerver.ErrHijack
 	}
 
-	w.fw.Close()
+	if w.cw != nil {
+		w.cw.Close()
+		w.cw = nil
+	}
 
 	return hijacker.Hijack()
 }
 
-func (w flateWriter) Close() error {
-	err := w.fw.Close()
+func (w *compressWriter) Close() error {
+	var err error
+	if w.cw != nil {
+		err = w.cw.Close()
+	}
 	if w.needClose {
 		_ = w.ResponseWriter.(io.Closer).Close()
 	}
@@ -72,38 +86,42 @@ func (w flateWriter) Close() error {
 	return err
 }
 
-func gzipWrapper(w http.ResponseWriter, needClose bool) (http.ResponseWriter, bool) {
-	return gzipWriter{
-		gw:             gzip.NewWriter(w),
-		ResponseWriter: w,
-		needClose:      needClose,
-	}, true
+func newGzipWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
 }
 
-func flateWrapper(w http.ResponseWriter, needClose bool) (http.ResponseWriter, bool) {
+func newFlateWriter(w io.Writer) io.WriteCloser {
 	fw, _ := flate.NewWriter(w, flate.DefaultCompression)
 
-	return flateWriter{
-		fw:             fw,
-		ResponseWriter: w,
-		needClose:      needClose,
-	}, true
+	return fw
 }
 
+func compressWrapper(encoding string, newWriter func(io.Writer) io.WriteCloser) zerver.ResponseWrapper {
+	return func(w http.ResponseWriter, needClose bool) (http.ResponseWriter, bool) {
+		return &compressWriter{
+			encoding:       encoding,
+			newWriter:      newWriter,
+			ResponseWriter: w,
+			needClose:      needClose,
+		}, true
+	}
+}
+
+var (
+	gzipWrapper  = compressWrapper(zerver.ENCODING_GZIP, newGzipWriter)
+	flateWrapper = compressWrapper(zerver.ENCODING_DEFLATE, newFlateWriter)
+)
+
+// Compress compress response by gzip or deflate if client accept it, response
+// which already has content encoding will not be compressed again
 func Compress(req zerver.Request, resp zerver.Response, chain zerver.FilterChain) {
 	encoding := req.AcceptEncodings()
 
 	if strings.Contains(encoding, zerver.ENCODING_GZIP) {
-		resp.SetContentEncoding(zerver.ENCODING_GZIP)
 		resp.Wrap(gzipWrapper)
 	} else if strings.Contains(encoding, zerver.ENCODING_DEFLATE) {
-		resp.SetContentEncoding(zerver.ENCODING_DEFLATE)
 		resp.Wrap(flateWrapper)
-	} else {
-		chain(req, resp)
-		return
 	}
 
 	chain(req, resp)
-	resp.RemoveHeader("Content-Length")
 }
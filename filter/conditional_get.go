@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cosiner/zerver"
+)
+
+type (
+	// NoConditionalGet can be attached to route as metadata to bypass ConditionalGet,
+	// it's useful for large or streaming responses
+	//
+	//  server.Handle("/export", zerver.WithMeta(handler, filter.NoConditionalGet(true)))
+	NoConditionalGet bool
+
+	// ConditionalGet buffer response of GET/HEAD request by zerver.BufferResponse,
+	// generate ETag from the body, and reply 304 with empty body if it's matched
+	// by If-None-Match.
+	//
+	// If handler has set ETag or Last-Modified before writing, response is not
+	// buffered, If-None-Match and If-Modified-Since are evaluated by them. Response
+	// which status is not 200, or exceed MaxSize, or flushed by handler such as
+	// streaming is written directly.
+	ConditionalGet struct {
+		// generate weak ETag, it should be used if the body will be changed by
+		// other writers such as compression, default strong
+		Weak bool
+		// max bytes of response to buffer, default 1M
+		MaxSize int64
+	}
+
+	// conditional hold conditional headers of request, request is destroyed
+	// before response, so they are saved
+	conditional struct {
+		filter          *ConditionalGet
+		ifNoneMatch     string
+		ifModifiedSince string
+	}
+)
+
+func (c *ConditionalGet) Init(zerver.Environment) error {
+	if c.MaxSize <= 0 {
+		c.MaxSize = 1 << 20
+	}
+
+	return nil
+}
+
+func (c *ConditionalGet) Destroy() {}
+
+func (c *ConditionalGet) Filter(req zerver.Request, resp zerver.Response, chain zerver.FilterChain) {
+	var skip NoConditionalGet
+	method := req.Method()
+	if (method != zerver.GET && method != zerver.HEAD) || (req.RouteMeta().Find(&skip) && bool(skip)) {
+		chain(req, resp)
+		return
+	}
+
+	cond := &conditional{
+		filter:          c,
+		ifNoneMatch:     req.Header(zerver.HEADER_IFNONEMATCH),
+		ifModifiedSince: req.Header(zerver.HEADER_IFMODIFIEDSINCE),
+	}
+	buf := zerver.BufferResponse(resp, int(c.MaxSize))
+	buf.OnHeader(cond.onHeader)
+	chain(req, resp)
+
+	if !buf.Buffering() || buf.Status() != http.StatusOK {
+		return
+	}
+	header := buf.Header()
+	if header.Get(zerver.HEADER_ETAG) == "" && header.Get(zerver.HEADER_LASTMODIFIED) == "" {
+		body := buf.Body()
+		if len(body) == 0 && method == zerver.HEAD {
+			return
+		}
+		header.Set(zerver.HEADER_ETAG, ETag(body, c.Weak))
+		if len(body) > 0 {
+			header.Set(zerver.HEADER_CONTENTLENGTH, strconv.Itoa(len(body)))
+		}
+	}
+	if cond.notModified(header) {
+		writeNotModified(buf)
+	}
+}
+
+// ETag generate ETag of data, weak ETag is prefixed with "W/"
+func ETag(data []byte, weak bool) string {
+	sum := sha1.Sum(data)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+	if weak {
+		tag = "W/" + tag
+	}
+
+	return tag
+}
+
+// onHeader stop buffering if status is not 200, or ETag/Last-Modified is set by
+// handler, or Content-Length exceed MaxSize
+func (c *conditional) onHeader(buf *zerver.ResponseBuffer) bool {
+	if buf.Status() != http.StatusOK {
+		return false
+	}
+
+	header := buf.Header()
+	if header.Get(zerver.HEADER_ETAG) != "" || header.Get(zerver.HEADER_LASTMODIFIED) != "" {
+		if !c.notModified(header) {
+			return false
+		}
+		writeNotModified(buf)
+		return true
+	}
+
+	length, err := strconv.ParseInt(header.Get(zerver.HEADER_CONTENTLENGTH), 10, 64)
+	return err != nil || length <= c.filter.MaxSize
+}
+
+// notModified check If-None-Match by weak comparison, If-Modified-Since is only
+// checked if If-None-Match is absent
+func (c *conditional) notModified(header http.Header) bool {
+	if inm := c.ifNoneMatch; inm != "" {
+		return zerver.MatchETag(inm, header.Get(zerver.HEADER_ETAG), true)
+	}
+
+	ims := c.ifModifiedSince
+	lastModified := header.Get(zerver.HEADER_LASTMODIFIED)
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
+
+func writeNotModified(buf *zerver.ResponseBuffer) {
+	header := buf.Header()
+	header.Del(zerver.HEADER_CONTENTTYPE)
+	header.Del(zerver.HEADER_CONTENTLENGTH)
+	buf.SetStatus(http.StatusNotModified)
+	buf.Discard()
+}
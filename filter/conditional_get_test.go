@@ -0,0 +1,189 @@
+package filter
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/ygo/log"
+	"github.com/cosiner/ygo/resource"
+	"github.com/cosiner/zerver"
+)
+
+func newTestServer(filter interface{}) *zerver.Server {
+	s := zerver.NewServer()
+	s.Log = log.Default()
+	s.ResMaster.DefUse(resource.RES_JSON, resource.JSON{})
+	if filter != nil {
+		s.Handle("/", filter)
+	}
+
+	return s
+}
+
+func serveTest(s *zerver.Server, w *httptest.ResponseRecorder, method, path string, header http.Header, body io.Reader) *httptest.ResponseRecorder {
+	if w == nil {
+		w = httptest.NewRecorder()
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	u, _ := url.Parse(path)
+	r := &http.Request{
+		Method:        method,
+		URL:           u,
+		Header:        header,
+		ContentLength: -1,
+		RemoteAddr:    "127.0.0.1:8080",
+	}
+	if body != nil {
+		r.Body = ioutil.NopCloser(body)
+		if sr, is := body.(*strings.Reader); is {
+			r.ContentLength = sr.Size()
+		}
+	}
+	s.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestConditionalGet(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var (
+		w       *httptest.ResponseRecorder
+		written int
+	)
+	s := newTestServer(&ConditionalGet{MaxSize: 8})
+	tt.Nil(s.Get("/data", func(req zerver.Request, resp zerver.Response) {
+		resp.WriteString("hello")
+		written = w.Body.Len()
+	}))
+	tt.Nil(s.Get("/etag", func(req zerver.Request, resp zerver.Response) {
+		resp.SetETag(`"v1"`)
+		resp.WriteString("hello")
+		written = w.Body.Len()
+	}))
+	tt.Nil(s.Get("/modified", func(req zerver.Request, resp zerver.Response) {
+		resp.SetLastModified(modTime)
+		resp.WriteString("hello")
+		written = w.Body.Len()
+	}))
+	tt.Nil(s.Get("/large", func(req zerver.Request, resp zerver.Response) {
+		resp.WriteString("hello world")
+	}))
+	tt.Nil(s.Get("/length", func(req zerver.Request, resp zerver.Response) {
+		resp.SetHeader(zerver.HEADER_CONTENTLENGTH, "11")
+		resp.WriteString("hello")
+		written = w.Body.Len()
+		resp.WriteString(" world")
+	}))
+	tt.Nil(s.Get("/stream", func(req zerver.Request, resp zerver.Response) {
+		resp.WriteString("hello")
+		resp.Flush()
+	}))
+	tt.Nil(s.Handle("/skip", zerver.WithMeta(zerver.MapHandler{
+		zerver.GET: func(req zerver.Request, resp zerver.Response) {
+			resp.WriteString("hello")
+		},
+	}, NoConditionalGet(true))))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		w = httptest.NewRecorder()
+		written = -1
+		return serveTest(s, w, zerver.GET, path, header, nil)
+	}
+
+	// strong ETag generated from body
+	etag := ETag([]byte("hello"), false)
+	serve("/data", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("hello", w.Body.String())
+	tt.Eq(0, written)
+	tt.Eq(etag, w.Header().Get(zerver.HEADER_ETAG))
+	tt.Eq("5", w.Header().Get(zerver.HEADER_CONTENTLENGTH))
+
+	serve("/data", http.Header{zerver.HEADER_IFNONEMATCH: {etag}})
+	tt.Eq(http.StatusNotModified, w.Code)
+	tt.Eq("", w.Body.String())
+	tt.Eq("", w.Header().Get(zerver.HEADER_CONTENTTYPE))
+	tt.Eq("", w.Header().Get(zerver.HEADER_CONTENTLENGTH))
+
+	serve("/data", http.Header{zerver.HEADER_IFNONEMATCH: {`"other"`}})
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("hello", w.Body.String())
+
+	// handler set ETag, response is not buffered
+	serve("/etag", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq(5, written)
+	tt.Eq(`"v1"`, w.Header().Get(zerver.HEADER_ETAG))
+
+	serve("/etag", http.Header{zerver.HEADER_IFNONEMATCH: {`W/"v1"`}})
+	tt.Eq(http.StatusNotModified, w.Code)
+	tt.Eq("", w.Body.String())
+
+	// handler set Last-Modified
+	serve("/modified", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq(5, written)
+	tt.Eq("", w.Header().Get(zerver.HEADER_ETAG))
+
+	serve("/modified", http.Header{zerver.HEADER_IFMODIFIEDSINCE: {modTime.Format(http.TimeFormat)}})
+	tt.Eq(http.StatusNotModified, w.Code)
+	tt.Eq("", w.Body.String())
+
+	serve("/modified", http.Header{zerver.HEADER_IFMODIFIEDSINCE: {modTime.Add(-time.Second).Format(http.TimeFormat)}})
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("hello", w.Body.String())
+
+	// exceed MaxSize
+	serve("/large", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("hello world", w.Body.String())
+	tt.Eq("", w.Header().Get(zerver.HEADER_ETAG))
+
+	serve("/length", nil)
+	tt.Eq(5, written)
+	tt.Eq("hello world", w.Body.String())
+	tt.Eq("", w.Header().Get(zerver.HEADER_ETAG))
+
+	// flushed by handler
+	serve("/stream", nil)
+	tt.True(w.Flushed)
+	tt.Eq("hello", w.Body.String())
+	tt.Eq("", w.Header().Get(zerver.HEADER_ETAG))
+
+	serve("/skip", nil)
+	tt.Eq("hello", w.Body.String())
+	tt.Eq("", w.Header().Get(zerver.HEADER_ETAG))
+}
+
+func TestConditionalGetWeak(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer(&ConditionalGet{Weak: true})
+	tt.Nil(s.Get("/data", func(req zerver.Request, resp zerver.Response) {
+		resp.WriteString("hello")
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	etag := ETag([]byte("hello"), true)
+	tt.True(strings.HasPrefix(etag, "W/"))
+
+	w := serveTest(s, nil, zerver.GET, "/data", nil, nil)
+	tt.Eq(etag, w.Header().Get(zerver.HEADER_ETAG))
+
+	// If-None-Match use weak comparison
+	w = serveTest(s, nil, zerver.GET, "/data", http.Header{zerver.HEADER_IFNONEMATCH: {ETag([]byte("hello"), false)}}, nil)
+	tt.Eq(http.StatusNotModified, w.Code)
+	tt.Eq("", w.Body.String())
+}
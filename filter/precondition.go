@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"time"
+
+	"github.com/cosiner/gohper/errors"
+	"github.com/cosiner/ygo/log"
+	"github.com/cosiner/zerver"
+)
+
+const ErrNilCurrent = errors.Err("current version getter of resource can't be nil")
+
+// Precondition protect resources from lost update, it get current version of the
+// requested resource by Current, then evaluate If-Match and If-Unmodified-Since,
+// 412 is reported if resource was changed, 428 is reported if preconditions are
+// required but missing. Handler should set ETag or Last-Modified of the updated
+// resource by Response.SetETag/SetLastModified.
+type Precondition struct {
+	// Current return current ETag and modify time of resource, empty etag and zero
+	// time mean the resource doesn't exist, it's required
+	Current func(zerver.Request) (etag string, modTime time.Time, err error)
+	// Required report 428 if both If-Match and If-Unmodified-Since are absent
+	Required bool
+	// Methods to check, default PUT, PATCH, DELETE
+	Methods []string
+
+	logger log.Logger
+}
+
+func (p *Precondition) Init(env zerver.Environment) error {
+	if p.Current == nil {
+		return ErrNilCurrent
+	}
+	if len(p.Methods) == 0 {
+		p.Methods = []string{zerver.PUT, zerver.PATCH, zerver.DELETE}
+	}
+	p.logger = env.Logger().Prefix("[Precondition]")
+
+	return nil
+}
+
+func (p *Precondition) Destroy() {}
+
+func (p *Precondition) Filter(req zerver.Request, resp zerver.Response, chain zerver.FilterChain) {
+	method := req.Method()
+	for _, m := range p.Methods {
+		if m != method {
+			continue
+		}
+
+		etag, modTime, err := p.Current(req)
+		if err != nil {
+			p.logger.Errorln(req.RemoteIP(), method, req.URL().Path, err.Error())
+			resp.ReportInternalServerError()
+			return
+		}
+		if !zerver.CheckPrecondition(req, resp, etag, modTime, p.Required) {
+			return
+		}
+		break
+	}
+
+	chain(req, resp)
+}
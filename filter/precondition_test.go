@@ -0,0 +1,82 @@
+package filter
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/zerver"
+)
+
+func TestPrecondition(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var (
+		version = 1
+		modTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		err     error
+		called  bool
+	)
+	s := newTestServer(&Precondition{
+		Current: func(zerver.Request) (string, time.Time, error) {
+			return zerver.VersionETag(version), modTime, err
+		},
+		Required: true,
+	})
+	update := func(req zerver.Request, resp zerver.Response) {
+		called = true
+		version++
+		resp.SetETag(zerver.VersionETag(version))
+	}
+	tt.Nil(s.Handle("/user", zerver.MapHandler{
+		zerver.GET: func(req zerver.Request, resp zerver.Response) {
+			called = true
+		},
+		zerver.PUT:    update,
+		zerver.DELETE: update,
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method string, header http.Header) int {
+		called = false
+		return serveTest(s, nil, method, "/user", header, nil).Code
+	}
+
+	// required but missing
+	tt.Eq(http.StatusPreconditionRequired, serve(zerver.PUT, nil))
+	tt.False(called)
+
+	// mismatch
+	tt.Eq(http.StatusPreconditionFailed, serve(zerver.PUT, http.Header{zerver.HEADER_IFMATCH: {`"2"`}}))
+	tt.False(called)
+	tt.Eq(http.StatusPreconditionFailed, serve(zerver.DELETE, http.Header{
+		zerver.HEADER_IFUNMODIFIEDSINCE: {modTime.Add(-time.Second).Format(http.TimeFormat)},
+	}))
+	tt.False(called)
+
+	// matched, pass through
+	tt.Eq(http.StatusOK, serve(zerver.PUT, http.Header{zerver.HEADER_IFMATCH: {`"1"`}}))
+	tt.True(called)
+	tt.Eq(2, version)
+	tt.Eq(http.StatusOK, serve(zerver.DELETE, http.Header{
+		zerver.HEADER_IFUNMODIFIEDSINCE: {modTime.Format(http.TimeFormat)},
+	}))
+	tt.True(called)
+
+	// other methods are not checked
+	tt.Eq(http.StatusOK, serve(zerver.GET, nil))
+	tt.True(called)
+
+	err = errors.New("database failed")
+	tt.Eq(http.StatusInternalServerError, serve(zerver.PUT, http.Header{zerver.HEADER_IFMATCH: {`"3"`}}))
+	tt.False(called)
+}
+
+func TestPreconditionNilCurrent(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer(&Precondition{})
+	tt.Eq(ErrNilCurrent, s.Router.Init(s))
+}
@@ -1,12 +1,15 @@
 package zerver
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"testing"
 
+	"github.com/cosiner/ygo/log"
 	"github.com/cosiner/ygo/resource"
 
 	"github.com/cosiner/gohper/testing2"
@@ -33,6 +36,46 @@ func (m *MockWriter) Header() http.Header {
 	return m.Headers
 }
 
+// newTestServer create a server with logger and default JSON resource
+func newTestServer() *Server {
+	s := NewServer()
+	s.Log = log.Default()
+	s.ResMaster.DefUse(resource.RES_JSON, resource.JSON{})
+
+	return s
+}
+
+// serveTest serve a request and return the written response, host of path is used
+// as request host, body length is known only if body has Len method
+func serveTest(s *Server, method, path string, header http.Header, body io.Reader) (*MockWriter, *bytes.Buffer) {
+	if header == nil {
+		header = make(http.Header)
+	}
+	u, _ := url.Parse(path)
+	r := &http.Request{
+		Method:     method,
+		Host:       u.Host,
+		URL:        u,
+		Header:     header,
+		RemoteAddr: "127.0.0.1:8080",
+	}
+	if body != nil {
+		r.Body = ioutil.NopCloser(body)
+		r.ContentLength = -1
+		if l, is := body.(interface {
+			Len() int
+		}); is {
+			r.ContentLength = int64(l.Len())
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewMockWriter(buf)
+	s.ServeHTTP(w, r)
+
+	return w, buf
+}
+
 func TestFilter(t *testing.T) {
 	tt := testing2.Wrap(t)
 	s := NewServer()
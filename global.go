@@ -4,19 +4,35 @@ import "strings"
 
 const (
 	// Http Header
-	HEADER_CONTENTTYPE     = "Content-Type"
-	HEADER_CONTENTLENGTH   = "Content-Length"
-	HEADER_SETCOOKIE       = "Set-Cookie"
-	HEADER_REFER           = "Referer"
-	HEADER_CONTENTENCODING = "Content-Encoding"
-	HEADER_USERAGENT       = "User-Agent"
-	HEADER_ACCEPT          = "Accept"
-	HEADER_ACCEPTENCODING  = "Accept-Encoding"
-	HEADER_CACHECONTROL    = "Cache-Control"
-	HEADER_EXPIRES         = "Expires"
-	HEADER_AUTHRIZATION    = "Authorization"
-	HEADER_METHODOVERRIDE  = "X-HTTP-Method-Override"
-	HEADER_REALIP          = "X-Real-IP"
+	HEADER_CONTENTTYPE        = "Content-Type"
+	HEADER_CONTENTLENGTH      = "Content-Length"
+	HEADER_SETCOOKIE          = "Set-Cookie"
+	HEADER_REFER              = "Referer"
+	HEADER_CONTENTENCODING    = "Content-Encoding"
+	HEADER_USERAGENT          = "User-Agent"
+	HEADER_ACCEPT             = "Accept"
+	HEADER_ACCEPTENCODING     = "Accept-Encoding"
+	HEADER_ACCEPTLANGUAGE     = "Accept-Language"
+	HEADER_CACHECONTROL       = "Cache-Control"
+	HEADER_EXPIRES            = "Expires"
+	HEADER_AUTHRIZATION       = "Authorization"
+	HEADER_METHODOVERRIDE     = "X-HTTP-Method-Override"
+	HEADER_REALIP             = "X-Real-IP"
+	HEADER_DEPRECATION        = "Deprecation"
+	HEADER_SUNSET             = "Sunset"
+	HEADER_ETAG               = "ETag"
+	HEADER_LASTMODIFIED       = "Last-Modified"
+	HEADER_VARY               = "Vary"
+	HEADER_RANGE              = "Range"
+	HEADER_IFRANGE            = "If-Range"
+	HEADER_IFMATCH            = "If-Match"
+	HEADER_IFNONEMATCH        = "If-None-Match"
+	HEADER_IFMODIFIEDSINCE    = "If-Modified-Since"
+	HEADER_IFUNMODIFIEDSINCE  = "If-Unmodified-Since"
+	HEADER_LASTEVENTID        = "Last-Event-ID"
+	HEADER_REQUESTID          = "X-Request-Id"
+	HEADER_CONTENTDISPOSITION = "Content-Disposition"
+	HEADER_LOCATION           = "Location"
 
 	// ContentEncoding
 	ENCODING_GZIP    = "gzip"
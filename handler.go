@@ -1,6 +1,7 @@
 package zerver
 
 import (
+	"net/http"
 	"strings"
 )
 
@@ -53,7 +54,9 @@ type (
 func EmptyHandlerFunc(Request, Response) {}
 
 // convertHandler convert a interfae to Handler,
-// only support Handler,MapHandler,MethodHandler, otherwise return nil
+// only support Handler,MapHandler,MethodHandler, error handler functions and
+// net/http handler, otherwise return nil, error handler functions only handle
+// GET and HEAD, use MapHandler with ErrorFunc for other methods
 func convertHandler(i interface{}) Handler {
 	switch h := i.(type) {
 	case Handler:
@@ -62,6 +65,12 @@ func convertHandler(i interface{}) Handler {
 		return HandlerFunc(h)
 	case map[string]HandleFunc:
 		return MapHandler(h)
+	case ErrorHandleFunc, func(Request, Response) error,
+		ValueHandleFunc, func(Request, Response) (interface{}, error):
+		fn := ErrorFunc(h)
+		return MapHandler{GET: fn, HEAD: fn}
+	case func(http.ResponseWriter, *http.Request):
+		return HTTPHandler(http.HandlerFunc(h))
 	case MethodHandler:
 		var comp Component
 		if c, is := h.(Component); is {
@@ -74,6 +83,8 @@ func convertHandler(i interface{}) Handler {
 			Component:     comp,
 			MethodHandler: h,
 		}
+	case http.Handler:
+		return HTTPHandler(h)
 	}
 
 	return nil
@@ -0,0 +1,419 @@
+package handler
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosiner/gohper/defval"
+	"github.com/cosiner/gohper/errors"
+	"github.com/cosiner/ygo/log"
+	"github.com/cosiner/zerver"
+)
+
+const (
+	// load balance algorithms
+	BALANCE_ROUNDROBIN = "round-robin"
+	BALANCE_LEASTCONN  = "least-conn"
+
+	ErrNoUpstream = errors.Err("proxy has no upstream")
+)
+
+type (
+	// Upstream is a backend server of proxy
+	Upstream struct {
+		// URL is the base url of upstream, such as "http://10.0.0.1:8080/api", the
+		// forwarded path will be appended to it
+		URL string
+		// DialTimeout is the timeout of connecting to upstream, default 10 seconds
+		DialTimeout time.Duration
+		// ResponseTimeout is the timeout of waiting response header after request
+		// is sent, 0 means no timeout, response body is not limited for streaming
+		ResponseTimeout time.Duration
+
+		url       *url.URL
+		dialer    *net.Dialer
+		transport *http.Transport
+		conns     int64 // active connections
+		fails     int32 // continuous failures
+		downUntil int64 // unix nano, upstream will not be used before it
+	}
+
+	// Proxy forward requests to upstreams, it run as a normal handler, so filters
+	// still apply to it. It should be registered with a remains-all pattern such as
+	// "/api/*path", the path variable will be appended to url of upstream, if there is
+	// no path variable, full request path is used.
+	//
+	// Upstream is passively marked as unavailable for FailTimeout after MaxFails
+	// continuous failures, if all upstreams are unavailable, 503 is reported.
+	// Websocket and other upgrade requests are passed through by hijacking the
+	// connection.
+	Proxy struct {
+		Upstreams []*Upstream
+		// Balance is load balance algorithm, default BALANCE_ROUNDROBIN
+		Balance string
+		// PathVar is the url variable name of forwarded path, default "path"
+		PathVar     string
+		MaxFails    int           // default 3
+		FailTimeout time.Duration // default 10 seconds
+		// PreserveHost forward the "Host" header of client instead of upstream's
+		PreserveHost bool
+		// Rewrite is called before request is forwarded, it can be used to change
+		// headers of request
+		Rewrite func(*http.Request)
+
+		next uint32
+		log  log.Logger
+	}
+)
+
+// hop-by-hop headers, they should not be forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+var proxyBufPool = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, 32*1024)
+	},
+}
+
+func (u *Upstream) init() error {
+	var err error
+	if u.url, err = url.Parse(u.URL); err != nil {
+		return err
+	}
+	if u.url.Scheme != "http" && u.url.Scheme != "https" {
+		return errors.Err("unsupported upstream scheme: " + u.URL)
+	}
+
+	if u.DialTimeout <= 0 {
+		u.DialTimeout = 10 * time.Second
+	}
+	u.dialer = &net.Dialer{
+		Timeout:   u.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	u.transport = &http.Transport{
+		DialContext:           u.dialer.DialContext,
+		ResponseHeaderTimeout: u.ResponseTimeout,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return nil
+}
+
+func (u *Upstream) available(now int64) bool {
+	return atomic.LoadInt64(&u.downUntil) <= now
+}
+
+// dial connect to upstream directly, it's used for upgrade requests
+func (u *Upstream) dial() (net.Conn, error) {
+	host := u.url.Host
+	if u.url.Port() == "" {
+		if u.url.Scheme == "https" {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+
+	if u.url.Scheme == "https" {
+		return tls.DialWithDialer(u.dialer, "tcp", host, &tls.Config{
+			ServerName: u.url.Hostname(),
+		})
+	}
+
+	return u.dialer.Dial("tcp", host)
+}
+
+func (p *Proxy) Init(env zerver.Environment) error {
+	if len(p.Upstreams) == 0 {
+		return ErrNoUpstream
+	}
+	for _, u := range p.Upstreams {
+		if err := u.init(); err != nil {
+			return err
+		}
+	}
+
+	defval.String(&p.Balance, BALANCE_ROUNDROBIN)
+	if p.Balance != BALANCE_ROUNDROBIN && p.Balance != BALANCE_LEASTCONN {
+		return errors.Err("unsupported load balance algorithm: " + p.Balance)
+	}
+	defval.String(&p.PathVar, "path")
+	defval.Int(&p.MaxFails, 3)
+	if p.FailTimeout <= 0 {
+		p.FailTimeout = 10 * time.Second
+	}
+	p.log = env.Logger().Prefix("[Proxy]")
+
+	return nil
+}
+
+func (p *Proxy) Destroy() {
+	for _, u := range p.Upstreams {
+		u.transport.CloseIdleConnections()
+	}
+}
+
+// Handler return same function for all methods
+func (p *Proxy) Handler(string) zerver.HandleFunc {
+	return p.serve
+}
+
+// pick choose an available upstream, nil returned if there is no one
+func (p *Proxy) pick() *Upstream {
+	now := time.Now().UnixNano()
+
+	if p.Balance == BALANCE_LEASTCONN {
+		var picked *Upstream
+		for _, u := range p.Upstreams {
+			if u.available(now) &&
+				(picked == nil || atomic.LoadInt64(&u.conns) < atomic.LoadInt64(&picked.conns)) {
+				picked = u
+			}
+		}
+
+		return picked
+	}
+
+	n := uint32(len(p.Upstreams))
+	start := atomic.AddUint32(&p.next, 1) - 1
+	for i := uint32(0); i < n; i++ {
+		if u := p.Upstreams[(start+i)%n]; u.available(now) {
+			return u
+		}
+	}
+
+	return nil
+}
+
+func (p *Proxy) fail(u *Upstream, err error) {
+	p.log.Warnln(u.URL, err)
+
+	if atomic.AddInt32(&u.fails, 1) >= int32(p.MaxFails) {
+		atomic.StoreInt32(&u.fails, 0)
+		atomic.StoreInt64(&u.downUntil, time.Now().Add(p.FailTimeout).UnixNano())
+		p.log.Warnln("mark upstream unavailable:", u.URL)
+	}
+}
+
+func (p *Proxy) success(u *Upstream) {
+	if atomic.LoadInt32(&u.fails) != 0 {
+		atomic.StoreInt32(&u.fails, 0)
+	}
+}
+
+func (p *Proxy) serve(req zerver.Request, resp zerver.Response) {
+	u := p.pick()
+	if u == nil {
+		resp.ReportServiceUnavailable()
+		return
+	}
+	atomic.AddInt64(&u.conns, 1)
+	defer atomic.AddInt64(&u.conns, -1)
+
+	r := zerver.HTTPRequest(req)
+	upgrade := isUpgradeRequest(r)
+	out := p.outRequest(req, r, u, upgrade)
+	if upgrade {
+		p.serveUpgrade(resp, out, u)
+		return
+	}
+
+	res, err := u.transport.RoundTrip(out)
+	if err != nil {
+		if r.Context().Err() != nil { // client gone
+			return
+		}
+
+		p.fail(u, err)
+		reportProxyError(resp, err)
+		return
+	}
+	p.success(u)
+
+	defer res.Body.Close()
+	copyProxyResponse(resp, res)
+}
+
+// outRequest create the request forwarded to upstream
+func (p *Proxy) outRequest(req zerver.Request, r *http.Request, u *Upstream, upgrade bool) *http.Request {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+
+	path := req.URLVar(p.PathVar)
+	if path == "" {
+		path = r.URL.Path
+	}
+	target := *u.url
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+	target.RawPath = ""
+	target.RawQuery = r.URL.RawQuery
+	out.URL = &target
+	if !p.PreserveHost {
+		out.Host = ""
+	}
+
+	header := out.Header
+	upgradeProto := header.Get("Upgrade")
+	for _, h := range strings.Split(header.Get("Connection"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			header.Del(h)
+		}
+	}
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+	if upgrade {
+		header.Set("Connection", "Upgrade")
+		header.Set("Upgrade", upgradeProto)
+	}
+
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		header.Set("X-Forwarded-For", ip)
+	}
+	header.Set("X-Forwarded-Host", r.Host)
+	if r.TLS != nil {
+		header.Set("X-Forwarded-Proto", "https")
+	} else {
+		header.Set("X-Forwarded-Proto", "http")
+	}
+
+	if p.Rewrite != nil {
+		p.Rewrite(out)
+	}
+
+	return out
+}
+
+// serveUpgrade send upgrade request to upstream, if upstream switch protocol,
+// hijack client connection and copy data between them
+func (p *Proxy) serveUpgrade(resp zerver.Response, out *http.Request, u *Upstream) {
+	conn, err := u.dial()
+	if err != nil {
+		p.fail(u, err)
+		reportProxyError(resp, err)
+		return
+	}
+	defer conn.Close()
+
+	if u.ResponseTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(u.ResponseTimeout))
+	}
+	br := bufio.NewReader(conn)
+	err = out.Write(conn)
+	var res *http.Response
+	if err == nil {
+		res, err = http.ReadResponse(br, out)
+	}
+	if err != nil {
+		p.fail(u, err)
+		reportProxyError(resp, err)
+		return
+	}
+	p.success(u)
+	conn.SetReadDeadline(time.Time{})
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		copyProxyResponse(resp, res)
+		return
+	}
+
+	client, rw, err := resp.Hijack()
+	if err != nil {
+		p.log.Warnln(err)
+		resp.ReportInternalServerError()
+		return
+	}
+	defer client.Close()
+
+	if err = res.Write(client); err != nil {
+		return
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(conn, rw.Reader)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(client, br)
+		errc <- err
+	}()
+	<-errc
+}
+
+// copyProxyResponse copy status, headers and body of upstream response, if
+// response length is unknown, it's flushed after each write for streaming
+func copyProxyResponse(resp zerver.Response, res *http.Response) {
+	resp.RemoveHeader(zerver.HEADER_CONTENTTYPE) // set by server before handler
+	for _, h := range hopHeaders {
+		res.Header.Del(h)
+	}
+	for name, values := range res.Header {
+		for i, v := range values {
+			if i == 0 {
+				resp.SetHeader(name, v)
+			} else {
+				resp.AddHeader(name, v)
+			}
+		}
+	}
+	resp.ReportStatus(res.StatusCode)
+
+	flush := res.ContentLength < 0
+	buf := proxyBufPool.Get().([]byte)
+	defer proxyBufPool.Put(buf)
+	for {
+		n, err := res.Body.Read(buf)
+		if n > 0 {
+			if _, err := resp.Write(buf[:n]); err != nil {
+				return
+			}
+			if flush {
+				resp.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func reportProxyError(resp zerver.Response, err error) {
+	if e, is := err.(net.Error); is && e.Timeout() {
+		resp.ReportGatewayTimeout()
+	} else {
+		resp.ReportBadGateway()
+	}
+}
+
+func isUpgradeRequest(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
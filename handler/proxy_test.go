@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/ygo/log"
+	"github.com/cosiner/ygo/resource"
+	"github.com/cosiner/zerver"
+)
+
+func newUpstream(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "echo" {
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			rw.Flush()
+			io.Copy(conn, rw)
+			return
+		}
+
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Path", r.URL.Path+"?"+r.URL.RawQuery)
+		w.Header().Set("X-Forwarded", r.Header.Get("X-Forwarded-For")+"|"+r.Header.Get("X-Forwarded-Host"))
+		io.Copy(w, r.Body)
+	}))
+}
+
+func TestProxy(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	up1, up2 := newUpstream("1"), newUpstream("2")
+	defer up1.Close()
+	defer up2.Close()
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	var authed bool
+	s := zerver.NewServer()
+	s.Log = log.Default()
+	s.ResMaster.DefUse(resource.RES_JSON, resource.JSON{})
+	tt.Nil(s.Handle("/api", func(req zerver.Request, resp zerver.Response, chain zerver.FilterChain) {
+		authed = true
+		chain(req, resp)
+	}))
+	tt.Nil(s.Handle("/api/*path", &Proxy{
+		Upstreams: []*Upstream{
+			{URL: up1.URL + "/v1"},
+			{URL: up2.URL + "/v1"},
+			{URL: down.URL},
+		},
+		MaxFails: 1,
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	front := httptest.NewServer(s)
+	defer front.Close()
+
+	var upstreams []string
+	for i := 0; i < 5; i++ {
+		res, err := http.Get(front.URL + "/api/users/1?fields=name")
+		tt.Nil(err)
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			upstreams = append(upstreams, res.Header.Get("X-Upstream"))
+			tt.Eq("/v1/users/1?fields=name", res.Header.Get("X-Path"))
+			tt.Eq("127.0.0.1|"+front.Listener.Addr().String(), res.Header.Get("X-Forwarded"))
+		} else {
+			tt.Eq(http.StatusBadGateway, res.StatusCode)
+		}
+	}
+	tt.True(authed)
+	tt.DeepEq([]string{"1", "2", "1", "2"}, upstreams) // failed upstream is skipped
+
+	// websocket passthrough
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	tt.Nil(err)
+	defer conn.Close()
+	conn.Write([]byte("GET /api/echo HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	tt.Nil(err)
+	tt.Eq(http.StatusSwitchingProtocols, res.StatusCode)
+	conn.Write([]byte("ping\n"))
+	line, err := br.ReadString('\n')
+	tt.Nil(err)
+	tt.Eq("ping\n", line)
+}
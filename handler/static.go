@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cosiner/gohper/defval"
+	"github.com/cosiner/gohper/errors"
+	"github.com/cosiner/zerver"
+)
+
+const (
+	ErrNoStaticRoot = errors.Err("static handler has no directory or file system")
+)
+
+type (
+	// Static serve files from a directory or a file system, it should be registered
+	// with a remains-all pattern such as "/assets/*path", the path variable is the
+	// file path relative to root.
+	//
+	// Only GET and HEAD are allowed, ETag, Last-Modified, Range and conditional
+	// headers are processed by Response.ServeContent. Directory is redirected to
+	// the path with trailing "/" before serving index file or entries.
+	Static struct {
+		// Dir is the root directory, it's used only if FS is nil
+		Dir string
+		FS  fs.FS
+		// PathVar is the url variable name of file path, default "path"
+		PathVar string
+		// Index is served for directory if exist, default "index.html"
+		Index string
+		// ListDir list directory entries if there is no index file
+		ListDir bool
+		// Fallback is served if file is not found, such as "index.html" for single
+		// page application, empty means report 404
+		Fallback string
+		// Precompressed serve "name.gz" if it exist and client accept gzip
+		Precompressed bool
+		// MaxAge is the seconds of "Cache-Control: max-age", 0 means not set
+		MaxAge int
+
+		cacheControl string
+	}
+)
+
+func (s *Static) Init(zerver.Environment) error {
+	if s.FS == nil {
+		if s.Dir == "" {
+			return ErrNoStaticRoot
+		}
+		s.FS = os.DirFS(s.Dir)
+	}
+
+	defval.String(&s.PathVar, "path")
+	defval.String(&s.Index, "index.html")
+	if s.MaxAge > 0 {
+		s.cacheControl = "max-age=" + strconv.Itoa(s.MaxAge)
+	}
+
+	return nil
+}
+
+func (s *Static) Destroy() {}
+
+func (s *Static) Handler(method string) zerver.HandleFunc {
+	if method == zerver.GET || method == zerver.HEAD {
+		return s.serve
+	}
+
+	return nil
+}
+
+func (s *Static) serve(req zerver.Request, resp zerver.Response) {
+	name := cleanPath(req.URLVar(s.PathVar))
+
+	f, stat, err := s.open(name)
+	if err == nil && stat.IsDir() {
+		f.Close()
+		if s.serveDirectory(req, resp, name) {
+			return
+		}
+		err = os.ErrNotExist
+	}
+
+	if err != nil && s.Fallback != "" {
+		name = cleanPath(s.Fallback)
+		f, stat, err = s.open(name)
+		if err == nil && stat.IsDir() {
+			f.Close()
+			err = os.ErrNotExist
+		}
+	}
+
+	if err != nil {
+		reportError(resp, err)
+		return
+	}
+
+	s.serveFile(req, resp, name, f, stat)
+}
+
+// serveDirectory serve index file or list directory entries, return false if
+// both of them is not available. Request path without trailing "/" is redirected
+// to the slash form, so relative links of the page are resolved correctly.
+func (s *Static) serveDirectory(req zerver.Request, resp zerver.Response, name string) bool {
+	index := path.Join(name, s.Index)
+	f, stat, err := s.open(index)
+	if err == nil && stat.IsDir() {
+		f.Close()
+		err = os.ErrNotExist
+	}
+	if err != nil && !s.ListDir {
+		return false
+	}
+
+	if loc, is := dirRedirect(req); is {
+		if err == nil {
+			f.Close()
+		}
+		resp.SetHeader(zerver.HEADER_LOCATION, loc)
+		resp.ReportMovedPermanently()
+		return true
+	}
+	if err == nil {
+		s.serveFile(req, resp, index, f, stat)
+		return true
+	}
+
+	entries, err := fs.ReadDir(s.FS, name)
+	if err != nil {
+		reportError(resp, err)
+		return true
+	}
+
+	base := req.URL().Path
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	resp.SetContentType("text/html; charset=utf-8", nil)
+	resp.WriteString("<pre>\n")
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: base + n}
+		resp.WriteString(`<a href="` + html.EscapeString(u.String()) + `">` + html.EscapeString(n) + "</a>\n")
+	}
+	resp.WriteString("</pre>\n")
+
+	return true
+}
+
+func (s *Static) serveFile(req zerver.Request, resp zerver.Response, name string, f fs.File, stat fs.FileInfo) {
+	var opt *zerver.ContentOption
+	tag := etag(stat, "")
+
+	if s.Precompressed {
+		resp.AddHeader(zerver.HEADER_VARY, zerver.HEADER_ACCEPTENCODING)
+		if strings.Contains(req.AcceptEncodings(), zerver.ENCODING_GZIP) {
+			gf, gstat, err := s.open(name + ".gz")
+			if err == nil && !gstat.IsDir() {
+				f.Close()
+				f, stat, tag = gf, gstat, etag(gstat, "gz")
+
+				typ := mime.TypeByExtension(path.Ext(name))
+				defval.String(&typ, "application/octet-stream")
+				opt = &zerver.ContentOption{ContentType: typ}
+				resp.SetContentEncoding(zerver.ENCODING_GZIP)
+			} else if err == nil {
+				gf.Close()
+			}
+		}
+	}
+	defer f.Close()
+
+	content, is := f.(io.ReadSeeker)
+	if !is {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			reportError(resp, err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if s.cacheControl != "" {
+		resp.SetHeader(zerver.HEADER_CACHECONTROL, s.cacheControl)
+	}
+	resp.SetETag(tag)
+
+	resp.ServeContent(req, name, stat.ModTime(), content, opt)
+}
+
+func (s *Static) open(name string) (fs.File, fs.FileInfo, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, stat, nil
+}
+
+// cleanPath convert url path to a valid path of fs.FS
+func cleanPath(name string) string {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
+func reportError(resp zerver.Response, err error) {
+	switch {
+	case os.IsNotExist(err):
+		resp.ReportNotFound()
+	case os.IsPermission(err):
+		resp.ReportForbidden()
+	default:
+		resp.ReportInternalServerError()
+	}
+}
+
+// dirRedirect return the slash form of request uri if path of it is not end with
+// "/", trailing "/" of url path is removed by server, so the original request uri
+// is checked
+func dirRedirect(req zerver.Request) (string, bool) {
+	r := zerver.HTTPRequest(req)
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+
+	p, query := uri, ""
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		p, query = uri[:i], uri[i:]
+	}
+	if strings.HasSuffix(p, "/") {
+		return "", false
+	}
+
+	return p + "/" + query, true
+}
+
+// etag generate a strong etag from modify time and size of file, variants of
+// same file such as compressed file should have different suffix
+func etag(stat fs.FileInfo, suffix string) string {
+	tag := strconv.FormatInt(stat.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(stat.Size(), 36)
+	if suffix != "" {
+		tag += "-" + suffix
+	}
+
+	return `"` + tag + `"`
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/ygo/resource"
+	"github.com/cosiner/zerver"
+	"github.com/cosiner/zerver/filter"
+)
+
+func gzipData(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+
+	return buf.Bytes()
+}
+
+func TestStatic(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	modTime := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("spa"), ModTime: modTime},
+		"app.js":          {Data: []byte("console.log(1)"), ModTime: modTime},
+		"app.js.gz":       {Data: gzipData("console.log(1)"), ModTime: modTime},
+		"docs/index.html": {Data: []byte("docs"), ModTime: modTime},
+		"img/a.png":       {Data: []byte("png"), ModTime: modTime},
+	}
+
+	s := zerver.NewServer()
+	s.ResMaster.DefUse(resource.RES_JSON, resource.JSON{})
+	s.RootFilters.Add(filter.Compress)
+	tt.Nil(s.Handle("/assets/*path", &Static{
+		FS:            fsys,
+		Fallback:      "index.html",
+		Precompressed: true,
+	}))
+	tt.Nil(s.Handle("/files/*path", &Static{
+		FS:      fsys,
+		ListDir: true,
+	}))
+	tt.Nil(s.RootFilters.Init(s))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		u, _ := url.Parse(path)
+		if header == nil {
+			header = make(http.Header)
+		}
+		s.ServeHTTP(w, &http.Request{
+			Method:     method,
+			URL:        u,
+			Header:     header,
+			RequestURI: path,
+		})
+
+		return w
+	}
+
+	w := serve(zerver.GET, "/assets/app.js", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("console.log(1)", w.Body.String())
+	etag := w.Header().Get(zerver.HEADER_ETAG)
+	tt.NE("", etag)
+	tt.Eq(modTime.Format(http.TimeFormat), w.Header().Get(zerver.HEADER_LASTMODIFIED))
+
+	w = serve(zerver.GET, "/assets/app.js", http.Header{zerver.HEADER_IFNONEMATCH: {etag}})
+	tt.Eq(http.StatusNotModified, w.Code)
+	tt.Eq(0, w.Body.Len())
+
+	w = serve(zerver.GET, "/assets/app.js", http.Header{zerver.HEADER_RANGE: {"bytes=0-6"}})
+	tt.Eq(http.StatusPartialContent, w.Code)
+	tt.Eq("console", w.Body.String())
+
+	w = serve(zerver.GET, "/assets/app.js", http.Header{zerver.HEADER_ACCEPTENCODING: {"gzip"}})
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq(zerver.ENCODING_GZIP, w.Header().Get(zerver.HEADER_CONTENTENCODING))
+	tt.DeepEq(fsys["app.js.gz"].Data, w.Body.Bytes()) // not compressed again
+	tt.NE(etag, w.Header().Get(zerver.HEADER_ETAG))
+
+	w = serve(zerver.GET, "/assets/docs?v=1", nil)
+	tt.Eq(http.StatusMovedPermanently, w.Code)
+	tt.Eq("/assets/docs/?v=1", w.Header().Get(zerver.HEADER_LOCATION))
+
+	w = serve(zerver.GET, "/assets/docs/", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("docs", w.Body.String())
+
+	w = serve(zerver.GET, "/assets/user/1", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq("spa", w.Body.String())
+
+	w = serve(zerver.HEAD, "/assets/img/a.png", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.Eq(0, w.Body.Len())
+
+	w = serve(zerver.POST, "/assets/app.js", nil)
+	tt.Eq(http.StatusMethodNotAllowed, w.Code)
+
+	w = serve(zerver.GET, "/files/../../etc/passwd", nil)
+	tt.Eq(http.StatusNotFound, w.Code)
+
+	w = serve(zerver.GET, "/files/img", nil)
+	tt.Eq(http.StatusMovedPermanently, w.Code)
+	tt.Eq("/files/img/", w.Header().Get(zerver.HEADER_LOCATION))
+
+	w = serve(zerver.GET, "/files/img/", nil)
+	tt.Eq(http.StatusOK, w.Code)
+	tt.True(bytes.Contains(w.Body.Bytes(), []byte(`<a href="/files/img/a.png">a.png</a>`)))
+}
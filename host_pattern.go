@@ -0,0 +1,180 @@
+package zerver
+
+import (
+	"log"
+	"strings"
+)
+
+const (
+	// host pattern kinds, also the match priority
+	_HOST_EXACT = iota
+	_HOST_VARIABLE
+	_HOST_WILDCARD
+	_HOST_ANY
+
+	// _MATCH_ALL is the pattern match every host, include empty host of task url
+	_MATCH_ALL = "*"
+)
+
+// HostPattern match host by pattern, pattern is consist of labels seperated by
+// '.' and an optional port. Label ":name" catch a single label as variable,
+// "*" can only be the first label, it match one or more labels, pattern "*"
+// match every host. Host is matched case-insensitively.
+// If port is not specified, host with any port will be matched.
+type HostPattern struct {
+	pattern string
+	port    string
+	labels  []string
+	vars    map[string]int
+	kind    int
+}
+
+// NewHostPattern parse host pattern, invalid pattern will panic
+func NewHostPattern(pattern string) *HostPattern {
+	p := &HostPattern{
+		pattern: pattern,
+		kind:    _HOST_EXACT,
+	}
+
+	host := strings.ToLower(strings.TrimSpace(pattern))
+	if host == "" {
+		log.Panicln("Empty host pattern is not allowed")
+	}
+	host, p.port = splitHostPort(host)
+
+	var varIndex int
+	p.labels = strings.Split(host, ".")
+	for i, l := range p.labels {
+		switch {
+		case l == "":
+			log.Panicln("Invalid host pattern, empty label: " + pattern)
+		case l == _MATCH_ALL:
+			if i != 0 {
+				log.Panicln("Invalid host pattern, '*' must be the first label: " + pattern)
+			}
+			p.kind = _HOST_WILDCARD
+			if len(p.labels) == 1 && p.port == "" {
+				p.kind = _HOST_ANY
+			}
+		case l[0] == ':':
+			if len(l) == 1 {
+				log.Panicln("Invalid host pattern, empty variable name: " + pattern)
+			}
+			if p.vars == nil {
+				p.vars = make(map[string]int)
+			}
+			p.vars[l[1:]] = varIndex
+			varIndex++
+			if p.kind == _HOST_EXACT {
+				p.kind = _HOST_VARIABLE
+			}
+		}
+	}
+
+	return p
+}
+
+// splitHostPort split host and port, if there is no port, port is empty
+func splitHostPort(host string) (string, string) {
+	i := strings.LastIndexByte(host, ':')
+	if i < 0 || i == len(host)-1 {
+		return host, ""
+	}
+
+	for _, c := range host[i+1:] {
+		if c < '0' || c > '9' {
+			return host, "" // variable label or ipv6 address
+		}
+	}
+
+	return host[:i], host[i+1:]
+}
+
+func (p *HostPattern) String() string {
+	return p.pattern
+}
+
+// Match check whether host match the pattern, host may contain port. If matched,
+// values of host variables are returned in order
+func (p *HostPattern) Match(host string) ([]string, bool) {
+	host, port := splitHostPort(strings.ToLower(host))
+
+	var values []string
+	if len(p.vars) != 0 {
+		values = make([]string, 0, len(p.vars))
+	}
+
+	return p.match(host, port, values)
+}
+
+func (p *HostPattern) match(host, port string, values []string) ([]string, bool) {
+	if p.port != "" && p.port != port {
+		return values, false
+	}
+
+	labels := p.labels
+	if p.kind == _HOST_ANY {
+		return values, true
+	}
+	if p.kind == _HOST_WILDCARD {
+		if len(labels) == 1 {
+			return values, true
+		}
+
+		labels = labels[1:]
+		skip := strings.Count(host, ".") + 1 - len(labels)
+		if skip < 1 {
+			return values, false
+		}
+		for ; skip > 0; skip-- {
+			host = host[strings.IndexByte(host, '.')+1:]
+		}
+	}
+
+	last := len(labels) - 1
+	for i, l := range labels {
+		label, end := host, strings.IndexByte(host, '.')
+		if (i == last) != (end < 0) {
+			return values, false
+		}
+		if i != last {
+			label, host = host[:end], host[end+1:]
+		}
+
+		if l[0] == ':' {
+			if label == "" {
+				return values, false
+			}
+			values = append(values, label)
+		} else if l != label {
+			return values, false
+		}
+	}
+
+	return values, true
+}
+
+// VarIndex return index of host variable in values returned by Match
+func (p *HostPattern) VarIndex(name string) (int, bool) {
+	index, has := p.vars[name]
+
+	return index, has
+}
+
+// HasVars report whether pattern has host variables
+func (p *HostPattern) HasVars() bool {
+	return len(p.vars) != 0
+}
+
+// Before check whether pattern should be tried before another one, exact hosts
+// first, then variable hosts, wildcard hosts with more labels, and "*" at last
+func (p *HostPattern) Before(o *HostPattern) bool {
+	if p.kind != o.kind {
+		return p.kind < o.kind
+	}
+	if p.kind == _HOST_WILDCARD {
+		return len(p.labels) > len(o.labels) // longer suffix first
+	}
+
+	return false
+}
@@ -0,0 +1,232 @@
+package zerver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cosiner/gohper/errors"
+)
+
+const (
+	ErrStreamClosed = errors.Err("stream is closed")
+
+	CONTENTTYPE_NDJSON = "application/x-ndjson"
+	CONTENTTYPE_JSON   = "application/json"
+
+	// HEADER_STREAMERROR is the trailer contains the error happened in the middle
+	// of streaming
+	HEADER_STREAMERROR = "X-Stream-Error"
+)
+
+// JSONStream write values as NDJSON or JSON array incrementally, output is flushed
+// every FlushCount values or FlushInterval. It stop on client disconnect, server
+// destroying or write error.
+//
+// Error happened in the middle of streaming is reported by Error, an object
+// {"error": message} is written as the last value, and it's also sent as trailer
+// X-Stream-Error. Message is the title of problem mapped by MapError, unknown
+// errors are logged and reported as "Internal Server Error".
+type JSONStream struct {
+	// flush after FlushCount values, default 100
+	FlushCount int
+	// flush if last flush is earlier than FlushInterval, default 1 second
+	FlushInterval time.Duration
+
+	req       Request
+	resp      Response
+	ctx       context.Context
+	closing   <-chan struct{}
+	array     bool
+	count     int
+	unflushed int
+	lastFlush time.Time
+	closed    bool
+}
+
+// NewNDJSONStream create a stream write each value as a line
+func NewNDJSONStream(req Request, resp Response) *JSONStream {
+	return newJSONStream(req, resp, false)
+}
+
+// NewJSONArrayStream create a stream write values as elements of a JSON array
+func NewJSONArrayStream(req Request, resp Response) *JSONStream {
+	return newJSONStream(req, resp, true)
+}
+
+func newJSONStream(req Request, resp Response, array bool) *JSONStream {
+	typ := CONTENTTYPE_NDJSON
+	if array {
+		typ = CONTENTTYPE_JSON
+	}
+	resp.SetContentType(typ, nil)
+	resp.SetHeader("Trailer", HEADER_STREAMERROR)
+
+	return &JSONStream{
+		FlushCount:    100,
+		FlushInterval: time.Second,
+		req:           req,
+		resp:          resp,
+		ctx:           HTTPRequest(req).Context(),
+		closing:       req.Server().Closing(),
+		array:         array,
+		lastFlush:     time.Now(),
+	}
+}
+
+// err return error if stream is closed, client disconnected or server is destroying
+func (s *JSONStream) err() error {
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	select {
+	case <-s.closing:
+		return ErrStreamClosed
+	default:
+	}
+
+	return s.ctx.Err()
+}
+
+// Send write a value
+func (s *JSONStream) Send(v interface{}) error {
+	if err := s.err(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.write(data)
+}
+
+func (s *JSONStream) write(data []byte) error {
+	var prefix string
+	switch {
+	case !s.array:
+	case s.count == 0:
+		prefix = "["
+	default:
+		prefix = ","
+	}
+	if prefix != "" {
+		if _, err := s.resp.WriteString(prefix); err != nil {
+			return err
+		}
+	}
+	if _, err := s.resp.Write(data); err != nil {
+		return err
+	}
+	if !s.array {
+		if _, err := s.resp.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	s.count++
+	s.unflushed++
+	if s.unflushed >= s.FlushCount || time.Since(s.lastFlush) >= s.FlushInterval {
+		s.flush()
+	}
+
+	return nil
+}
+
+func (s *JSONStream) flush() {
+	s.resp.Flush()
+	s.unflushed = 0
+	s.lastFlush = time.Now()
+}
+
+// SendAll write values returned by next until it return io.EOF, error returned by
+// next is reported by Error
+func (s *JSONStream) SendAll(next func() (interface{}, error)) error {
+	for {
+		v, err := next()
+		if err == io.EOF {
+			return s.Close()
+		}
+		if err != nil {
+			s.Error(err)
+			return err
+		}
+		if err = s.Send(v); err != nil {
+			return err
+		}
+	}
+}
+
+// SendChan write values received from channel until it's closed, if the stream
+// is stopped, caller should stop sending to the channel
+func (s *JSONStream) SendChan(values <-chan interface{}) error {
+	for {
+		select {
+		case v, ok := <-values:
+			if !ok {
+				return s.Close()
+			}
+			if err := s.Send(v); err != nil {
+				return err
+			}
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-s.closing:
+			return ErrStreamClosed
+		}
+	}
+}
+
+// Error report an error happened in the middle of streaming, then close the
+// stream. If nothing was written, status of the problem is reported.
+func (s *JSONStream) Error(err error) error {
+	if s.closed {
+		return nil
+	}
+
+	p := MapError(s.req, err)
+	if p == nil {
+		s.req.Logger().Errorln("stream", s.req.Method(), s.req.URL().Path, err.Error())
+		p = NewProblem(http.StatusInternalServerError, "")
+	}
+	if s.count == 0 {
+		s.resp.ReportStatus(p.Status)
+	}
+
+	data, e := json.Marshal(map[string]string{"error": p.Title})
+	if e == nil {
+		e = s.write(data)
+	}
+	s.resp.SetHeader(HEADER_STREAMERROR, strings.NewReplacer("\r", " ", "\n", " ").Replace(p.Title))
+	if e == nil {
+		e = s.Close()
+	}
+
+	return e
+}
+
+// Close finish the stream, for JSON array, close the array
+func (s *JSONStream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.array {
+		end := "]"
+		if s.count == 0 {
+			end = "[]"
+		}
+		if _, err := s.resp.WriteString(end); err != nil {
+			return err
+		}
+	}
+	s.flush()
+
+	return nil
+}
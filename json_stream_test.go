@@ -0,0 +1,79 @@
+package zerver
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func TestJSONStream(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+
+	iter := func(n int, err error) func() (interface{}, error) {
+		i := 0
+		return func() (interface{}, error) {
+			if i == n {
+				if err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			i++
+			return map[string]int{"id": i}, nil
+		}
+	}
+	tt.Nil(s.Handle("/ndjson", MapHandler{GET: func(req Request, resp Response) {
+		NewNDJSONStream(req, resp).SendAll(iter(3, nil))
+	}}))
+	tt.Nil(s.Handle("/array", MapHandler{GET: func(req Request, resp Response) {
+		stream := NewJSONArrayStream(req, resp)
+		stream.FlushCount = 1
+		stream.SendAll(iter(2, errors.New("database\nfailed")))
+	}}))
+	tt.Nil(s.Handle("/mapped", MapHandler{GET: func(req Request, resp Response) {
+		NewNDJSONStream(req, resp).SendAll(iter(0, errTestNoUser))
+	}}))
+	tt.Nil(s.Handle("/empty", MapHandler{GET: func(req Request, resp Response) {
+		values := make(chan interface{})
+		close(values)
+		NewJSONArrayStream(req, resp).SendChan(values)
+	}}))
+	tt.Nil(s.Router.Init(s))
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(ts.URL + path)
+		tt.Nil(err)
+		body, err := ioutil.ReadAll(resp.Body)
+		tt.Nil(err)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	resp, body := get("/ndjson")
+	tt.Eq(CONTENTTYPE_NDJSON, resp.Header.Get(HEADER_CONTENTTYPE))
+	tt.Eq("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", body)
+	tt.Eq("", resp.Trailer.Get(HEADER_STREAMERROR))
+
+	resp, body = get("/array")
+	tt.Eq(http.StatusOK, resp.StatusCode)
+	tt.Eq(`[{"id":1},{"id":2},{"error":"Internal Server Error"}]`, body) // detail is only logged
+	tt.Eq("Internal Server Error", resp.Trailer.Get(HEADER_STREAMERROR))
+
+	resp, body = get("/mapped")
+	tt.Eq(http.StatusNotFound, resp.StatusCode)
+	tt.Eq("{\"error\":\"Not Found\"}\n", body)
+	tt.Eq("Not Found", resp.Trailer.Get(HEADER_STREAMERROR))
+
+	_, body = get("/empty")
+	tt.Eq("[]", body)
+}
@@ -0,0 +1,73 @@
+package zerver
+
+import (
+	"log"
+	"reflect"
+)
+
+type (
+	// RouteMeta is metadata attached to a route, each element can be any type,
+	// such as documentation of route, required roles, body size limit. It can be
+	// accessed by filters and handlers through Request.RouteMeta, it should not
+	// be modified at request time.
+	RouteMeta []interface{}
+
+	// metaHandler attach metadata to handler, metadata will be moved to route when
+	// the handler is registered
+	metaHandler struct {
+		handler Handler
+		meta    RouteMeta
+	}
+
+	// Route is a registered handler and it's pattern and metadata
+	Route struct {
+		Pattern string
+		Handler Handler
+		Meta    RouteMeta
+	}
+)
+
+// WithMeta attach metadata to handler, if handler is nil, metadata is only added to
+// the route, it's useful for routes registered by HandleFunc/Get/Post... Metadata
+// registered to the same route will be merged.
+func WithMeta(handler interface{}, meta ...interface{}) Handler {
+	m := metaHandler{meta: meta}
+	if handler != nil {
+		if m.handler = convertHandler(handler); m.handler == nil {
+			log.Panicln("Not a Handler")
+		}
+	}
+
+	return m
+}
+
+func (h metaHandler) Init(env Environment) error {
+	return h.handler.Init(env)
+}
+
+func (h metaHandler) Destroy() {
+	h.handler.Destroy()
+}
+
+func (h metaHandler) Handler(method string) HandleFunc {
+	return h.handler.Handler(method)
+}
+
+// Find find the first metadata which has same type as the value ptr point to, if
+// found, store it to ptr and return true.
+//
+//	type Roles []string
+//	var roles Roles
+//	if req.RouteMeta().Find(&roles) { ... }
+func (m RouteMeta) Find(ptr interface{}) bool {
+	v := reflect.ValueOf(ptr).Elem()
+	t := v.Type()
+	for _, meta := range m {
+		if meta != nil && reflect.TypeOf(meta) == t {
+			v.Set(reflect.ValueOf(meta))
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package zerver
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+type testRoles []string
+
+type testBodyLimit int64
+
+func TestRouteMeta(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	s.RootFilters.Add(func(req Request, resp Response, chain FilterChain) {
+		var roles testRoles
+		if req.RouteMeta().Find(&roles) && req.Header("X-Role") != roles[0] {
+			resp.ReportForbidden()
+			return
+		}
+		chain(req, resp)
+	})
+
+	var limit testBodyLimit
+	handler := func(req Request, resp Response) {
+		limit = 0
+		req.RouteMeta().Find(&limit)
+	}
+	tt.Nil(s.Handle("/admin/users", WithMeta(MapHandler{GET: handler}, testRoles{"admin"})))
+	tt.Nil(s.Handle("/upload/:name", WithMeta(MapHandler{POST: handler}, testBodyLimit(10<<20))))
+	tt.Nil(s.Get("/public", handler))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method, path, role string) int {
+		w, _ := serveTest(s, method, path, http.Header{"X-Role": {role}}, nil)
+		return w.Status
+	}
+
+	tt.Eq(http.StatusForbidden, serve(GET, "/admin/users", "user"))
+	tt.Eq(http.StatusOK, serve(GET, "/admin/users", "admin"))
+	tt.Eq(http.StatusOK, serve(POST, "/upload/a.txt", ""))
+	tt.Eq(testBodyLimit(10<<20), limit)
+	tt.Eq(http.StatusOK, serve(GET, "/public", ""))
+	tt.Eq(testBodyLimit(0), limit)
+
+	var buf bytes.Buffer
+	s.PrintRouteTree(&buf)
+	tt.True(bytes.Contains(buf.Bytes(), []byte("meta: [[admin]]")))
+}
@@ -0,0 +1,386 @@
+package zerver
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/cosiner/gohper/defval"
+	"github.com/cosiner/gohper/errors"
+)
+
+const (
+	ErrNotMultipart          = errors.Err("request is not multipart")
+	ErrFileTooLarge          = errors.Err("uploaded file is too large")
+	ErrMultipartTooLarge     = errors.Err("multipart body is too large")
+	ErrContentTypeNotAllowed = errors.Err("content type of uploaded file is not allowed")
+)
+
+type (
+	// MultipartOption limit multipart request, zero value means default
+	MultipartOption struct {
+		// max size of each file, default 32M
+		MaxFileSize int64
+		// max size of whole body, default 64M
+		MaxTotalSize int64
+		// max size of each file kept in memory, larger file will be stored to temp
+		// file, it's also the max size of each non-file value, default 1M
+		MaxMemory int64
+		// allowed content types of files, such as "image/png", "image/*", default
+		// allow all
+		AllowedTypes []string
+		// directory to store temp files, default os.TempDir()
+		TempDir string
+	}
+
+	// MultipartReader iterate parts of multipart request as streams, size limits
+	// are enforced on read
+	MultipartReader struct {
+		reader *multipart.Reader
+		opt    *MultipartOption
+		size   int64
+	}
+
+	// MultipartPart is a part of multipart request, it's a file if FileName()
+	// is not empty
+	MultipartPart struct {
+		*multipart.Part
+		// bytes has been read
+		Size int64
+
+		limit  int64
+		reader *MultipartReader
+	}
+
+	// MultipartForm is the parsed multipart request
+	MultipartForm struct {
+		Values url.Values
+		Files  map[string][]*UploadedFile
+	}
+
+	// UploadedFile is a file of multipart form, it's stored in memory or temp file
+	UploadedFile struct {
+		Field       string
+		Filename    string
+		ContentType string
+		Header      textproto.MIMEHeader
+		Size        int64
+
+		data []byte
+		path string
+		temp bool
+	}
+)
+
+var defaultMultipartOption = &MultipartOption{}
+
+func init() {
+	defaultMultipartOption.init()
+}
+
+func (o *MultipartOption) init() {
+	if o.MaxFileSize <= 0 {
+		o.MaxFileSize = 32 << 20
+	}
+	if o.MaxTotalSize <= 0 {
+		o.MaxTotalSize = 64 << 20
+	}
+	if o.MaxMemory <= 0 {
+		o.MaxMemory = 1 << 20
+	}
+	defval.String(&o.TempDir, os.TempDir())
+}
+
+// allowed check whether content type is in allowed types
+func (o *MultipartOption) allowed(typ string) bool {
+	if len(o.AllowedTypes) == 0 {
+		return true
+	}
+
+	typ, _, _ = mime.ParseMediaType(typ)
+	for _, t := range o.AllowedTypes {
+		if t == typ {
+			return true
+		}
+		if matched, _ := path.Match(t, typ); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isMultipart(req *http.Request) bool {
+	typ, _, _ := mime.ParseMediaType(req.Header.Get(HEADER_CONTENTTYPE))
+
+	return typ == "multipart/form-data" || typ == "multipart/mixed"
+}
+
+// MultipartReader return a reader to iterate parts of request, if option is nil,
+// default option is used. It can't be used with Multipart or Params, because the
+// request body can only be read once.
+func (req *request) MultipartReader(opt *MultipartOption) (*MultipartReader, error) {
+	if opt == nil {
+		opt = defaultMultipartOption
+	} else {
+		o := *opt
+		o.init()
+		opt = &o
+	}
+
+	if !isMultipart(req.request) {
+		return nil, ErrNotMultipart
+	}
+	if req.request.ContentLength > opt.MaxTotalSize {
+		return nil, ErrMultipartTooLarge
+	}
+
+	r, err := req.request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultipartReader{
+		reader: r,
+		opt:    opt,
+	}, nil
+}
+
+// NextPart return next part, if there is no more parts, io.EOF is returned. If
+// part is a file but it's content type is not allowed, ErrContentTypeNotAllowed
+// is returned. Reading part return ErrFileTooLarge or ErrMultipartTooLarge if
+// exceed the limit.
+func (r *MultipartReader) NextPart() (*MultipartPart, error) {
+	p, err := r.reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+
+	part := &MultipartPart{
+		Part:   p,
+		limit:  r.opt.MaxMemory,
+		reader: r,
+	}
+	if p.FileName() != "" {
+		if !r.opt.allowed(part.ContentType()) {
+			return nil, ErrContentTypeNotAllowed
+		}
+		part.limit = r.opt.MaxFileSize
+	}
+
+	return part, nil
+}
+
+// ContentType return content type of part, default "application/octet-stream"
+func (p *MultipartPart) ContentType() string {
+	if typ := p.Header.Get(HEADER_CONTENTTYPE); typ != "" {
+		return typ
+	}
+
+	return "application/octet-stream"
+}
+
+func (p *MultipartPart) Read(b []byte) (int, error) {
+	// read at most one byte more than limit to detect exceeding
+	if max := p.limit - p.Size + 1; int64(len(b)) > max {
+		b = b[:max]
+	}
+	if max := p.reader.opt.MaxTotalSize - p.reader.size + 1; int64(len(b)) > max {
+		b = b[:max]
+	}
+
+	n, err := p.Part.Read(b)
+	p.Size += int64(n)
+	p.reader.size += int64(n)
+	if p.Size > p.limit {
+		if p.FileName() != "" {
+			return n, ErrFileTooLarge
+		}
+
+		return n, ErrMultipartTooLarge
+	}
+	if p.reader.size > p.reader.opt.MaxTotalSize {
+		return n, ErrMultipartTooLarge
+	}
+
+	return n, err
+}
+
+// Multipart parse all parts of request, files larger than MaxMemory are stored in
+// temp files, they will be removed when request is destroyed. The parsed form
+// is cached, option is only used on first call, if it's nil, default option is
+// used. Params also use the parsed form for multipart request.
+func (req *request) Multipart(opt *MultipartOption) (*MultipartForm, error) {
+	if req.multipart != nil || req.multipartErr != nil {
+		return req.multipart, req.multipartErr
+	}
+
+	form, err := req.parseMultipart(opt)
+	if err != nil {
+		req.multipartErr = err
+		if form != nil {
+			form.RemoveAll()
+		}
+
+		return nil, err
+	}
+	req.multipart = form
+
+	return form, nil
+}
+
+func (req *request) parseMultipart(opt *MultipartOption) (*MultipartForm, error) {
+	r, err := req.MultipartReader(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	form := &MultipartForm{
+		Values: make(url.Values),
+		Files:  make(map[string][]*UploadedFile),
+	}
+
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return form, err
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			var buf bytes.Buffer
+			if _, err = io.Copy(&buf, part); err != nil {
+				return form, err
+			}
+			form.Values[name] = append(form.Values[name], buf.String())
+		} else {
+			file, err := part.save(r.opt)
+			if file != nil {
+				form.Files[name] = append(form.Files[name], file)
+			}
+			if err != nil {
+				return form, err
+			}
+		}
+	}
+}
+
+// save read part to memory, if it's larger than MaxMemory, store it to temp file
+func (p *MultipartPart) save(opt *MultipartOption) (*UploadedFile, error) {
+	file := &UploadedFile{
+		Field:       p.FormName(),
+		Filename:    p.FileName(),
+		ContentType: p.ContentType(),
+		Header:      p.Header,
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, p, opt.MaxMemory+1)
+	if err == io.EOF {
+		file.data = buf.Bytes()
+		file.Size = n
+
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := ioutil.TempFile(opt.TempDir, "zerver-upload-")
+	if err != nil {
+		return nil, err
+	}
+	file.path = fd.Name()
+	file.temp = true // even if failed, it will be removed
+
+	n, err = io.Copy(fd, io.MultiReader(&buf, p))
+	file.Size = n
+	if e := fd.Close(); err == nil {
+		err = e
+	}
+
+	return file, err
+}
+
+// File return the first file with the field name
+func (f *MultipartForm) File(name string) *UploadedFile {
+	if files := f.Files[name]; len(files) != 0 {
+		return files[0]
+	}
+
+	return nil
+}
+
+// Value return the first value with the field name
+func (f *MultipartForm) Value(name string) string {
+	return f.Values.Get(name)
+}
+
+// RemoveAll remove all temp files
+func (f *MultipartForm) RemoveAll() error {
+	var err error
+	for _, files := range f.Files {
+		for _, file := range files {
+			if file.temp {
+				if e := os.Remove(file.path); e != nil && !os.IsNotExist(e) && err == nil {
+					err = e
+				}
+				file.temp = false
+			}
+		}
+	}
+
+	return err
+}
+
+// Open open the file content
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	if f.path == "" {
+		return ioutil.NopCloser(bytes.NewReader(f.data)), nil
+	}
+
+	return os.Open(f.path)
+}
+
+// Path return the file path if it's stored in file system
+func (f *UploadedFile) Path() string {
+	return f.path
+}
+
+// SaveTo save file to the path, temp file is moved if possible, after saved, the
+// file will not be removed when request is destroyed
+func (f *UploadedFile) SaveTo(filename string) error {
+	if f.temp && os.Rename(f.path, filename) == nil {
+		f.path = filename
+		f.temp = false
+
+		return nil
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package zerver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func multipartBody(values map[string]string, files map[string]string, typ string) (*bytes.Buffer, string) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	for k, v := range values {
+		w.WriteField(k, v)
+	}
+	for name, content := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="`+name+`"; filename="`+name+`.txt"`)
+		h.Set(HEADER_CONTENTTYPE, typ)
+		part, _ := w.CreatePart(h)
+		part.Write([]byte(content))
+	}
+	w.Close()
+
+	return buf, w.FormDataContentType()
+}
+
+func TestMultipart(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+
+	var (
+		opt      = &MultipartOption{MaxFileSize: 16, MaxMemory: 8, AllowedTypes: []string{"text/*"}}
+		form     *MultipartForm
+		err      error
+		tmpPath  string
+		name     string
+		contents []string
+	)
+	tt.Nil(s.Handle("/upload", MapHandler{
+		POST: func(req Request, resp Response) {
+			form, err = req.Multipart(opt)
+			name = req.Param("name")
+			contents, tmpPath = nil, ""
+			if err != nil {
+				return
+			}
+			for _, f := range form.Files["file"] {
+				r, _ := f.Open()
+				data, _ := ioutil.ReadAll(r)
+				r.Close()
+				contents = append(contents, string(data))
+				if f.Path() != "" {
+					tmpPath = f.Path()
+				}
+			}
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(body *bytes.Buffer, typ string) {
+		serveTest(s, POST, "/upload", http.Header{HEADER_CONTENTTYPE: {typ}}, body)
+	}
+
+	serve(multipartBody(map[string]string{"name": "zerver"}, map[string]string{"file": "0123456789"}, "text/plain"))
+	tt.Nil(err)
+	tt.Eq("zerver", name)
+	tt.DeepEq([]string{"0123456789"}, contents)
+	tt.NE("", tmpPath)
+	_, e := os.Stat(tmpPath)
+	tt.True(os.IsNotExist(e)) // removed on request destroy
+
+	serve(multipartBody(nil, map[string]string{"file": "abc"}, "text/plain"))
+	tt.Nil(err)
+	tt.DeepEq([]string{"abc"}, contents)
+	tt.Eq("", tmpPath)
+
+	serve(multipartBody(nil, map[string]string{"file": strings.Repeat("a", 17)}, "text/plain"))
+	tt.Eq(ErrFileTooLarge, err)
+
+	serve(multipartBody(nil, map[string]string{"file": "abc"}, "image/png"))
+	tt.Eq(ErrContentTypeNotAllowed, err)
+
+	serve(bytes.NewBufferString("name=zerver"), "application/x-www-form-urlencoded")
+	tt.Eq(ErrNotMultipart, err)
+	tt.Eq("zerver", name)
+}
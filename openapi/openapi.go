@@ -0,0 +1,274 @@
+// Package openapi generate OpenAPI 3 document from routes registered to router.
+//
+// Operations of route are documented by route metadata:
+//
+//	server.Handle("/user/:id", zerver.WithMeta(handler,
+//		openapi.Operation{Method: "GET", Summary: "get user", Response: User{}},
+//	))
+//
+// Methods processed by handler but not documented are also included with path
+// parameters only.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cosiner/zerver"
+	"github.com/cosiner/zerver/monitor"
+)
+
+const (
+	// parameter locations
+	IN_PATH   = "path"
+	IN_QUERY  = "query"
+	IN_HEADER = "header"
+	IN_COOKIE = "cookie"
+
+	VERSION = "3.0.3"
+)
+
+type (
+	// Info is the information of api
+	Info struct {
+		Title       string
+		Version     string
+		Description string
+	}
+
+	// Operation document a method of route, it should be attached to route by
+	// zerver.WithMeta
+	Operation struct {
+		Method      string
+		Summary     string
+		Description string
+		Tags        []string
+		Params      []Param
+		// Request is the request body type, it can be an example value such as User{},
+		// or a nil pointer such as (*User)(nil), nil means no request body
+		Request interface{}
+		// Response is the response body type, same as Request
+		Response   interface{}
+		Deprecated bool
+	}
+
+	// Param is a parameter of operation, path parameters are generated from route
+	// pattern if they are not documented
+	Param struct {
+		Name        string
+		In          string // default IN_QUERY
+		Description string
+		Required    bool
+		// Type is an example value of parameter type, default string
+		Type interface{}
+	}
+
+	// Handler serve the OpenAPI document of router, the document is generated on
+	// first request
+	Handler struct {
+		Router zerver.Router
+		Info   Info
+
+		once sync.Once
+		doc  []byte
+		err  error
+	}
+)
+
+var methods = []string{zerver.GET, zerver.POST, zerver.PUT, zerver.PATCH, zerver.DELETE}
+
+// Generate generate OpenAPI document of all routes registered to router
+func Generate(rt zerver.Router, info Info) ([]byte, error) {
+	return json.Marshal(document(rt.Routes(), info))
+}
+
+func document(routes []zerver.Route, info Info) object {
+	s := newSchemas()
+	paths := make(object)
+
+	for _, route := range routes {
+		path, vars := pathOf(route.Pattern)
+
+		ops := make(object)
+		for _, meta := range route.Meta {
+			if op, is := meta.(Operation); is {
+				ops[strings.ToLower(op.Method)] = s.operation(&op, vars)
+			}
+		}
+		if route.Handler != nil {
+			for _, m := range methods {
+				name := strings.ToLower(m)
+				if _, has := ops[name]; !has && route.Handler.Handler(m) != nil {
+					ops[name] = s.operation(&Operation{Method: m}, vars)
+				}
+			}
+		}
+
+		if len(ops) != 0 {
+			paths[path] = ops
+		}
+	}
+
+	doc := object{
+		"openapi": VERSION,
+		"info": object{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+	}
+	if len(s.components) != 0 {
+		doc["components"] = object{"schemas": s.components}
+	}
+
+	return doc
+}
+
+// pathOf convert route pattern to OpenAPI path, ":name" and "*name" is converted to
+// "{name}", path variable names are also returned
+func pathOf(pattern string) (string, []string) {
+	sections := strings.Split(pattern, "/")
+	var vars []string
+	for i, sec := range sections {
+		if j := strings.LastIndexAny(sec, ":*"); j >= 0 {
+			name := sec[j+1:]
+			if name == "" {
+				name = "var" + strconv.Itoa(len(vars))
+			}
+			vars = append(vars, name)
+			sections[i] = sec[:j] + "{" + name + "}"
+		}
+	}
+
+	return strings.Join(sections, "/"), vars
+}
+
+// String return method and summary of operation, it's used in route tree
+func (op Operation) String() string {
+	if op.Summary == "" {
+		return op.Method
+	}
+
+	return op.Method + "(" + op.Summary + ")"
+}
+
+func (s *schemas) operation(op *Operation, vars []string) object {
+	o := object{
+		"responses": object{
+			"200": s.response(op.Response),
+		},
+	}
+	if op.Summary != "" {
+		o["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		o["description"] = op.Description
+	}
+	if len(op.Tags) != 0 {
+		o["tags"] = op.Tags
+	}
+	if op.Deprecated {
+		o["deprecated"] = true
+	}
+	if op.Request != nil {
+		o["requestBody"] = object{
+			"required": true,
+			"content": object{
+				"application/json": object{"schema": s.schemaOf(op.Request)},
+			},
+		}
+	}
+
+	var params []interface{}
+	documented := make(map[string]bool)
+	for _, p := range op.Params {
+		if p.In == "" {
+			p.In = IN_QUERY
+		}
+		if p.In == IN_PATH {
+			documented[p.Name] = true
+		}
+		params = append(params, s.param(p))
+	}
+	for _, v := range vars {
+		if !documented[v] {
+			params = append(params, s.param(Param{Name: v, In: IN_PATH}))
+		}
+	}
+	if len(params) != 0 {
+		o["parameters"] = params
+	}
+
+	return o
+}
+
+func (s *schemas) param(p Param) object {
+	typ := p.Type
+	if typ == nil {
+		typ = ""
+	}
+
+	o := object{
+		"name":     p.Name,
+		"in":       p.In,
+		"required": p.Required || p.In == IN_PATH,
+		"schema":   s.schemaOf(typ),
+	}
+	if p.Description != "" {
+		o["description"] = p.Description
+	}
+
+	return o
+}
+
+func (s *schemas) response(typ interface{}) object {
+	o := object{"description": http.StatusText(http.StatusOK)}
+	if typ != nil {
+		o["content"] = object{
+			"application/json": object{"schema": s.schemaOf(typ)},
+		}
+	}
+
+	return o
+}
+
+func (h *Handler) Init(zerver.Environment) error { return nil }
+
+func (h *Handler) Destroy() {}
+
+func (h *Handler) Handler(method string) zerver.HandleFunc {
+	if method == zerver.GET {
+		return h.Serve
+	}
+
+	return nil
+}
+
+// Serve write the document to response, it can also be used as a monitor
+func (h *Handler) Serve(req zerver.Request, resp zerver.Response) {
+	h.once.Do(func() {
+		h.doc, h.err = Generate(h.Router, h.Info)
+	})
+
+	if h.err != nil {
+		resp.ReportInternalServerError()
+		return
+	}
+
+	resp.SetContentType("application/json", nil)
+	resp.Write(h.doc)
+}
+
+// EnableMonitor add the document to monitor as "/openapi", it should be called
+// before monitor.Enable
+func EnableMonitor(rt zerver.Router, info Info) {
+	h := &Handler{
+		Router: rt,
+		Info:   info,
+	}
+	monitor.Handle("/openapi", "OpenAPI document", h.Serve)
+}
@@ -0,0 +1,93 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/zerver"
+)
+
+type Base struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created,omitempty"`
+}
+
+type User struct {
+	Base
+	Name    string   `json:"name" description:"user name"`
+	Tags    []string `json:"tags,omitempty"`
+	Friends []*User  `json:"friends,omitempty"`
+	secret  string
+	Ignored string `json:"-"`
+}
+
+func TestGenerate(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	rt := zerver.NewRouter()
+	tt.Nil(rt.Handle("/users/:id", zerver.WithMeta(zerver.MapHandler{
+		zerver.GET: zerver.EmptyHandlerFunc,
+		zerver.PUT: zerver.EmptyHandlerFunc,
+	}, Operation{
+		Method:   zerver.GET,
+		Summary:  "get user",
+		Tags:     []string{"user"},
+		Params:   []Param{{Name: "fields", Type: []string{}}},
+		Response: User{},
+	}, Operation{
+		Method:  zerver.PUT,
+		Request: (*User)(nil),
+	})))
+	tt.Nil(rt.Post("/users", zerver.EmptyHandlerFunc))
+	tt.Nil(rt.Handle("/users", zerver.WithMeta(nil, Operation{Method: zerver.POST, Summary: "create user"})))
+
+	data, err := Generate(rt, Info{Title: "test", Version: "1.0"})
+	tt.Nil(err)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary    string
+			Parameters []struct {
+				Name     string
+				In       string
+				Required bool
+				Schema   map[string]interface{}
+			}
+			RequestBody map[string]interface{}
+			Responses   map[string]struct {
+				Content map[string]struct {
+					Schema map[string]interface{}
+				}
+			}
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{}
+				Required   []string
+			}
+		}
+	}
+	tt.Nil(json.Unmarshal(data, &doc))
+	tt.Eq(VERSION, doc.OpenAPI)
+
+	get := doc.Paths["/users/{id}"]["get"]
+	tt.Eq("get user", get.Summary)
+	tt.Eq(2, len(get.Parameters))
+	tt.Eq("fields", get.Parameters[0].Name)
+	tt.Eq("array", get.Parameters[0].Schema["type"])
+	tt.Eq("id", get.Parameters[1].Name)
+	tt.Eq(IN_PATH, get.Parameters[1].In)
+	tt.True(get.Parameters[1].Required)
+	tt.Eq("#/components/schemas/User", get.Responses["200"].Content["application/json"].Schema["$ref"])
+	tt.NotNil(doc.Paths["/users/{id}"]["put"].RequestBody)
+	tt.Eq("create user", doc.Paths["/users"]["post"].Summary)
+
+	user := doc.Components.Schemas["User"]
+	tt.Eq(5, len(user.Properties))
+	tt.Eq("date-time", user.Properties["created"]["format"])
+	tt.Eq("user name", user.Properties["name"]["description"])
+	tt.DeepEq([]string{"id", "name"}, user.Required)
+}
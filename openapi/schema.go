@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemas reflect go types to schemas, named struct types are stored as
+// components and referenced by name
+type schemas struct {
+	names      map[reflect.Type]string
+	components map[string]interface{}
+}
+
+type object map[string]interface{}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+func newSchemas() *schemas {
+	return &schemas{
+		names:      make(map[reflect.Type]string),
+		components: make(map[string]interface{}),
+	}
+}
+
+// schemaOf return schema of the type of value, value can be an example value
+// such as User{}, or a nil pointer such as (*User)(nil)
+func (s *schemas) schemaOf(v interface{}) object {
+	if t, is := v.(reflect.Type); is {
+		return s.schema(t)
+	}
+
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) object {
+	if t == nil {
+		return object{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return object{"type": "string", "format": "date-time"}
+	case bytesType:
+		return object{"type": "string", "format": "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return object{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return object{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return object{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return object{"type": "number", "format": "double"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return object{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+
+		return object{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+
+	return object{} // interface, any type
+}
+
+// component register named struct type as component, return the component name
+func (s *schemas) component(t reflect.Type) string {
+	if name, has := s.names[t]; has {
+		return name
+	}
+
+	name := t.Name()
+	if _, has := s.components[name]; has {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndexByte(pkg, '/')+1:] + "." + name
+	}
+	s.names[t] = name
+	s.components[name] = nil // placeholder for recursive type
+	s.components[name] = s.structSchema(t)
+
+	return name
+}
+
+func (s *schemas) structSchema(t reflect.Type) object {
+	props := make(object)
+	var required []string
+	s.fields(t, props, &required)
+
+	schema := object{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// fields collect properties of struct fields by json tag, fields of embedded struct
+// without name are promoted, fields without "omitempty" are required
+func (s *schemas) fields(t reflect.Type, props object, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.fields(ft, props, required)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.schema(f.Type)
+		if strings.Contains(opts, "string") {
+			schema = object{"type": "string"}
+		}
+		if desc := f.Tag.Get("description"); desc != "" {
+			if _, isRef := schema["$ref"]; isRef {
+				schema = object{"allOf": []interface{}{schema}}
+			}
+			schema["description"] = desc
+		}
+		props[name] = schema
+
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -11,10 +11,9 @@ import (
 var (
 	// pathVarCount is common url path variable count
 	// match functions of router will create a slice use it as capcity to store
-	// all path variable values
-	// to get best performance, it should commonly set to the average, default, it's 2
+	// all path variable values, if it's less than the max variables count of routes,
+	// router will use the later
 	pathVarCount int
-	filterCount  int
 )
 
 type requestEnv struct {
@@ -25,7 +24,6 @@ type requestEnv struct {
 type serverPool struct {
 	requestEnvPool sync.Pool
 	varIndexerPool sync.Pool
-	filtersPool    sync.Pool
 	otherPools     map[int]*sync.Pool
 }
 
@@ -41,10 +39,6 @@ func init() {
 	_defaultPool.varIndexerPool.New = func() interface{} {
 		return &urlVarIndexer{values: make([]string, 0, pathVarCount)}
 	}
-
-	_defaultPool.filtersPool.New = func() interface{} {
-		return make([]Filter, 0, filterCount)
-	}
 }
 
 func ReigisterPool(id int, newFunc func() interface{}) error {
@@ -69,10 +63,6 @@ func newVarIndexerFromPool() *urlVarIndexer {
 	return _defaultPool.varIndexerPool.Get().(*urlVarIndexer)
 }
 
-func newFiltersFromPool() []Filter {
-	return _defaultPool.filtersPool.Get().([]Filter)
-}
-
 func recycleRequestEnv(req *requestEnv) {
 	_defaultPool.requestEnvPool.Put(req)
 }
@@ -81,13 +71,6 @@ func recycleVarIndexer(indexer URLVarIndexer) {
 	_defaultPool.varIndexerPool.Put(indexer)
 }
 
-func recycleFilters(filters []Filter) {
-	if filters != nil {
-		filters = filters[:0]
-		_defaultPool.filtersPool.Put(filters)
-	}
-}
-
 func RecycleTo(id int, value interface{}) {
 	_defaultPool.otherPools[id].Put(value)
 }
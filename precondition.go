@@ -0,0 +1,110 @@
+package zerver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cosiner/gohper/errors"
+)
+
+const (
+	ErrPreconditionFailed   = errors.Err("precondition failed")
+	ErrPreconditionRequired = errors.Err("precondition required")
+)
+
+// VersionETag create a strong ETag from version of resource
+func VersionETag(version interface{}) string {
+	return `"` + fmt.Sprint(version) + `"`
+}
+
+// MatchETag check whether etag is matched by one of the tags in header such as
+// If-Match or If-None-Match, "*" match any etag. Weak comparison ignore the "W/"
+// prefix, strong comparison never match weak tags.
+func MatchETag(header, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Precondition evaluate If-Match by strong comparison with etag, If-Unmodified-Since
+// is only evaluated if If-Match is absent. Empty etag and zero modTime mean the
+// resource doesn't exist. If required and both headers are absent,
+// ErrPreconditionRequired is returned.
+func (req *request) Precondition(etag string, modTime time.Time, required bool) error {
+	if im := req.Header(HEADER_IFMATCH); im != "" {
+		exists := etag != "" || !modTime.IsZero()
+		if (exists && strings.TrimSpace(im) == "*") || MatchETag(im, etag, false) {
+			return nil
+		}
+		return ErrPreconditionFailed
+	}
+
+	ius := req.Header(HEADER_IFUNMODIFIEDSINCE)
+	if ius == "" {
+		if required {
+			return ErrPreconditionRequired
+		}
+		return nil
+	}
+	since, err := http.ParseTime(ius)
+	if err != nil || modTime.IsZero() || modTime.Truncate(time.Second).After(since) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// CheckPrecondition evaluate preconditions of request by current etag and modify
+// time of resource, if failed, 412 or 428 is reported, and error message is sent
+// with key "error"
+//
+//	if !zerver.CheckPrecondition(req, resp, zerver.VersionETag(user.Version), time.Time{}, true) {
+//		return
+//	}
+func CheckPrecondition(req Request, resp Response, etag string, modTime time.Time, required bool) bool {
+	err := req.Precondition(etag, modTime, required)
+	if err == nil {
+		return true
+	}
+
+	if err == ErrPreconditionRequired {
+		resp.ReportPreconditionRequired()
+	} else {
+		resp.ReportPreconditionFailed()
+	}
+	resp.Send("error", err.Error())
+
+	return false
+}
+
+// SetETag set ETag of response
+func (resp *response) SetETag(etag string) {
+	resp.SetHeader(HEADER_ETAG, etag)
+}
+
+// SetLastModified set Last-Modified of response
+func (resp *response) SetLastModified(t time.Time) {
+	resp.SetHeader(HEADER_LASTMODIFIED, t.UTC().Format(http.TimeFormat))
+}
@@ -0,0 +1,57 @@
+package zerver
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func TestMatchETag(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	tt.True(MatchETag(`"a", "b"`, `"b"`, false))
+	tt.True(MatchETag(`*`, `"b"`, false))
+	tt.False(MatchETag(`*`, ``, false))
+	tt.False(MatchETag(`W/"b"`, `"b"`, false))
+	tt.False(MatchETag(`"b"`, `W/"b"`, false))
+	tt.True(MatchETag(`W/"b"`, `"b"`, true))
+	tt.True(MatchETag(`"a", W/"b"`, `W/"b"`, true))
+}
+
+func TestPrecondition(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	version := 1
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt.Nil(s.Handle("/", MapHandler{PUT: func(req Request, resp Response) {
+		if !CheckPrecondition(req, resp, VersionETag(version), modTime, true) {
+			return
+		}
+		version++
+		resp.SetETag(VersionETag(version))
+	}}))
+	tt.Nil(s.Router.Init(s))
+
+	put := func(header http.Header) *MockWriter {
+		w, _ := serveTest(s, PUT, "/", header, nil)
+		return w
+	}
+
+	tt.Eq(http.StatusPreconditionRequired, put(http.Header{}).Status)
+
+	w := put(http.Header{HEADER_IFMATCH: {`"1"`}})
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq(`"2"`, w.Headers.Get(HEADER_ETAG))
+	tt.Eq(http.StatusPreconditionFailed, put(http.Header{HEADER_IFMATCH: {`"1"`}}).Status)
+	tt.Eq(http.StatusPreconditionFailed, put(http.Header{HEADER_IFMATCH: {`W/"2"`}}).Status)
+	tt.Eq(http.StatusOK, put(http.Header{HEADER_IFMATCH: {`*`}}).Status)
+
+	since := func(t time.Time) http.Header {
+		return http.Header{HEADER_IFUNMODIFIEDSINCE: {t.Format(http.TimeFormat)}}
+	}
+	tt.Eq(http.StatusOK, put(since(modTime)).Status)
+	tt.Eq(http.StatusPreconditionFailed, put(since(modTime.Add(-time.Second))).Status)
+}
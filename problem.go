@@ -0,0 +1,230 @@
+package zerver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+const (
+	CONTENTTYPE_PROBLEM    = "application/problem+json"
+	CONTENTTYPE_PROBLEMXML = "application/problem+xml"
+)
+
+type (
+	// ErrorHandleFunc is a handler function return error, error is reported by
+	// ReportError
+	ErrorHandleFunc func(Request, Response) error
+
+	// ValueHandleFunc is a handler function return a value and error, value is sent
+	// by Response.Send with empty key if there is no error, otherwise error is
+	// reported by ReportError
+	ValueHandleFunc func(Request, Response) (interface{}, error)
+
+	// Problem is a problem details document of RFC 7807, it can be returned by
+	// handler as error directly
+	Problem struct {
+		XMLName  xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+		Type     string   `json:"type,omitempty" xml:"type,omitempty"`
+		Title    string   `json:"title" xml:"title"`
+		Status   int      `json:"status" xml:"status"`
+		Detail   string   `json:"detail,omitempty" xml:"detail,omitempty"`
+		Instance string   `json:"instance,omitempty" xml:"instance,omitempty"`
+
+		// extension members
+		RequestID string      `json:"requestId,omitempty" xml:"requestId,omitempty"`
+		Errors    interface{} `json:"errors,omitempty" xml:"errors,omitempty"`
+	}
+
+	// StatusError is an error which has a http status code
+	StatusError interface {
+		error
+		StatusCode() int
+	}
+
+	// ErrorMapper convert an error to problem, return nil if it's not recognized
+	ErrorMapper func(Request, error) *Problem
+)
+
+var (
+	errorStatusLock sync.RWMutex
+	errorStatus     = map[error]int{
+		ErrBodyTooLarge:         http.StatusRequestEntityTooLarge,
+		ErrMultipartTooLarge:    http.StatusRequestEntityTooLarge,
+		ErrFileTooLarge:         http.StatusRequestEntityTooLarge,
+		ErrUnsupportedMedia:     http.StatusUnsupportedMediaType,
+		ErrPreconditionFailed:   http.StatusPreconditionFailed,
+		ErrPreconditionRequired: http.StatusPreconditionRequired,
+	}
+)
+
+// NewProblem create a problem, title is default the status text
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+
+	return p.Title + ": " + p.Detail
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// RegisterErrorStatus register the status code of an error value, it's used by
+// ReportError, duplicate registration will panic
+func RegisterErrorStatus(err error, status int) {
+	errorStatusLock.Lock()
+	defer errorStatusLock.Unlock()
+
+	if _, has := errorStatus[err]; has {
+		log.Panicln("Duplicate error status:", err.Error())
+	}
+	errorStatus[err] = status
+}
+
+// MapError convert error to problem, server's ErrorMapper is used first, then
+// *Problem, StatusError, ValidationErrors, BindErrors and registered errors are
+// recognized, otherwise nil is returned
+func MapError(req Request, err error) *Problem {
+	if mapper := req.Server().ErrorMapper; mapper != nil {
+		if p := mapper(req, err); p != nil {
+			return p
+		}
+	}
+
+	switch e := err.(type) {
+	case *Problem:
+		return e
+	case StatusError:
+		return NewProblem(e.StatusCode(), e.Error())
+	case ValidationErrors:
+		p := NewProblem(http.StatusUnprocessableEntity, "")
+		p.Errors = e.Translate(acceptLanguage(req))
+		return p
+	case BindErrors:
+		p := NewProblem(http.StatusBadRequest, "")
+		p.Errors = e
+		return p
+	}
+
+	errorStatusLock.RLock()
+	status, has := errorStatus[err]
+	errorStatusLock.RUnlock()
+	if has {
+		return NewProblem(status, err.Error())
+	}
+
+	return nil
+}
+
+// ReportError report error as problem details, unknown error is reported as 500
+// and logged with request id of header X-Request-Id, if it's absent, a random id
+// is generated and set to response header
+//
+// Problem is rendered by the negotiated resource, the content type of JSON and XML
+// is replaced with application/problem+json and application/problem+xml.
+func ReportError(req Request, resp Response, err error) {
+	p := MapError(req, err)
+	if p == nil {
+		id := req.Header(HEADER_REQUESTID)
+		if id == "" {
+			id = newRequestID()
+			resp.SetHeader(HEADER_REQUESTID, id)
+		}
+		req.Logger().Errorln("request", id, req.Method(), req.URL().Path, err.Error())
+
+		p = NewProblem(http.StatusInternalServerError, "")
+		p.RequestID = id
+	}
+
+	sendProblem(resp, p)
+}
+
+func newRequestID() string {
+	var id [8]byte
+	rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
+
+func sendProblem(resp Response, p *Problem) error {
+	resp.ReportStatus(p.Status)
+
+	res := resp.Resource()
+	if res == nil {
+		resp.SetContentType("text/plain; charset=utf-8", nil)
+		_, err := resp.WriteString(p.Error())
+		return err
+	}
+
+	data, err := res.Marshal(p)
+	if err == nil {
+		typ, _, _ := mime.ParseMediaType(resp.raw().header.Get(HEADER_CONTENTTYPE))
+		switch typ {
+		case "application/json":
+			resp.SetContentType(CONTENTTYPE_PROBLEM, nil)
+		case "application/xml", "text/xml":
+			resp.SetContentType(CONTENTTYPE_PROBLEMXML, nil)
+		}
+	} else if data, err = json.Marshal(p); err == nil {
+		// problem can't be encoded by resource such as CSV and form, use JSON
+		resp.SetContentType(CONTENTTYPE_PROBLEM, nil)
+	} else {
+		resp.ReportInternalServerError()
+		return err
+	}
+	_, err = resp.Write(data)
+
+	return err
+}
+
+// ErrorFunc convert ErrorHandleFunc or ValueHandleFunc to HandleFunc, it can be
+// used by MapHandler or Router.Get/Post..., otherwise panic
+//
+//	server.Handle("/user/:id", zerver.MapHandler{
+//		zerver.GET: zerver.ErrorFunc(getUser),
+//	})
+func ErrorFunc(fn interface{}) HandleFunc {
+	switch h := fn.(type) {
+	case func(Request, Response) error:
+		return ErrorHandleFunc(h).handle
+	case ErrorHandleFunc:
+		return h.handle
+	case func(Request, Response) (interface{}, error):
+		return ValueHandleFunc(h).handle
+	case ValueHandleFunc:
+		return h.handle
+	}
+
+	log.Panicln("Not an error handler function")
+	return nil
+}
+
+func (fn ErrorHandleFunc) handle(req Request, resp Response) {
+	if err := fn(req, resp); err != nil {
+		ReportError(req, resp, err)
+	}
+}
+
+func (fn ValueHandleFunc) handle(req Request, resp Response) {
+	v, err := fn(req, resp)
+	if err != nil {
+		ReportError(req, resp, err)
+	} else if v != nil {
+		resp.Send("", v)
+	}
+}
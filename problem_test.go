@@ -0,0 +1,123 @@
+package zerver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/ygo/resource"
+)
+
+var errTestNoUser = errors.New("user not found")
+
+func init() {
+	RegisterErrorStatus(errTestNoUser, http.StatusNotFound)
+}
+
+func TestReportError(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	s.ErrorMapper = func(req Request, err error) *Problem {
+		if err == ErrHijack {
+			return &Problem{Type: "/problems/hijack", Title: "Hijack", Status: http.StatusNotImplemented}
+		}
+		return nil
+	}
+
+	var err error
+	tt.Nil(s.Handle("/value", MapHandler{
+		GET: ErrorFunc(func(req Request, resp Response) (interface{}, error) {
+			return map[string]string{"name": "zerver"}, err
+		}),
+	}))
+	tt.Nil(s.Handle("/error", func(req Request, resp Response) error {
+		return err
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method, path string) (*MockWriter, Problem) {
+		w, body := serveTest(s, method, path, nil, nil)
+
+		var p Problem
+		json.Unmarshal(body.Bytes(), &p)
+		return w, p
+	}
+
+	w, _ := serve(GET, "/value")
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq(resource.RES_JSON, w.Headers.Get(HEADER_CONTENTTYPE))
+
+	err = errTestNoUser
+	w, p := serve(GET, "/value")
+	tt.Eq(http.StatusNotFound, w.Status)
+	tt.Eq(CONTENTTYPE_PROBLEM, w.Headers.Get(HEADER_CONTENTTYPE))
+	tt.Eq(http.StatusNotFound, p.Status)
+	tt.Eq("user not found", p.Detail)
+
+	err = NewProblem(http.StatusConflict, "name exists")
+	w, p = serve(GET, "/error")
+	tt.Eq(http.StatusConflict, w.Status)
+	tt.Eq("Conflict", p.Title)
+
+	w, _ = serve(HEAD, "/error")
+	tt.Eq(http.StatusConflict, w.Status)
+
+	// error handler function only handle GET and HEAD
+	for _, method := range []string{POST, PUT, PATCH, DELETE} {
+		w, _ = serve(method, "/error")
+		tt.Eq(http.StatusMethodNotAllowed, w.Status, method)
+	}
+
+	err = ErrHijack
+	_, p = serve(GET, "/error")
+	tt.Eq("/problems/hijack", p.Type)
+
+	err = errors.New("database failed")
+	w, p = serve(GET, "/error")
+	tt.Eq(http.StatusInternalServerError, w.Status)
+	tt.Eq("", p.Detail)
+	tt.NE("", p.RequestID)
+	tt.Eq(p.RequestID, w.Headers.Get(HEADER_REQUESTID))
+
+	err = nil
+	w, _ = serve(GET, "/error")
+	tt.Eq(http.StatusOK, w.Status)
+}
+
+func TestReportErrorUnencodable(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	s.ResMaster.Use(CONTENTTYPE_CSV, CSVResource{})
+	s.ResMaster.Use(CONTENTTYPE_FORM, FormResource{})
+	tt.Nil(s.Handle("/users", MapHandler{
+		POST: func(req Request, resp Response) {
+			var u testUser
+			if err := req.Receive(&u); err != nil {
+				ReportInvalid(req, resp, err)
+			}
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	// problem can't be encoded as CSV or form, it's sent as JSON
+	for _, accept := range []string{CONTENTTYPE_CSV, CONTENTTYPE_FORM} {
+		header := http.Header{HEADER_CONTENTTYPE: {"application/json"}, HEADER_ACCEPT: {accept}}
+		w, body := serveTest(s, POST, "/users", header, strings.NewReader(`{"age":20}`))
+		tt.Eq(http.StatusUnprocessableEntity, w.Status, accept)
+		tt.Eq(CONTENTTYPE_PROBLEM, w.Headers.Get(HEADER_CONTENTTYPE), accept)
+
+		var p struct {
+			Status int
+			Errors []map[string]string
+		}
+		tt.Nil(json.Unmarshal(body.Bytes(), &p))
+		tt.Eq(http.StatusUnprocessableEntity, p.Status)
+		tt.Eq(1, len(p.Errors))
+		tt.Eq("name", p.Errors[0]["field"])
+	}
+}
@@ -3,9 +3,11 @@ package zerver
 import (
 	"encoding/base64"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cosiner/gohper/errors"
 
@@ -15,7 +17,10 @@ import (
 )
 
 const (
-	ErrNoResourceType = errors.Err("there is no this resource type on server")
+	ErrNoResourceType      = errors.Err("there is no this resource type on server")
+	ErrUnsupportedBindType = errors.Err("unsupported field type for binding")
+	ErrBodyTooLarge        = errors.Err("request body is too large")
+	ErrUnsupportedMedia    = errors.Err("unsupported request content type")
 )
 
 type (
@@ -40,6 +45,9 @@ type (
 		Authorization() (string, bool)
 		BasicAuth() (string, string)
 		Cookie(name string) string
+		// SignedCookie/EncryptedCookie verify/decrypt value by server's CookieCodec
+		SignedCookie(name string, ptr interface{}) error
+		EncryptedCookie(name string, ptr interface{}) error
 
 		Param(name string) string
 		Params(name string) []string
@@ -51,7 +59,26 @@ type (
 		io.Reader
 		URLVarIndexer
 
+		// MultipartReader iterate parts of multipart request as streams
+		MultipartReader(*MultipartOption) (*MultipartReader, error)
+		// Multipart parse multipart request, files are stored in memory or temp files
+		Multipart(*MultipartOption) (*MultipartForm, error)
+
+		// Receive decode request body, then validate it. Unsupported Content-Type
+		// is not rejected by server, since handler may read raw body, Receive
+		// return ErrUnsupportedMedia, handler should report it by ReportInvalid
+		// or ReportError as 415
 		Receive(interface{}) error
+		// Bind fill a struct from url variables, query, headers, form and body,
+		// then validate it
+		Bind(interface{}) error
+		// Precondition evaluate If-Match and If-Unmodified-Since by current ETag
+		// and modify time of resource
+		Precondition(etag string, modTime time.Time, required bool) error
+
+		// raw return the underlying request, it's promoted to wrappers which
+		// embed a Request
+		raw() *request
 		destroy() error
 	}
 
@@ -67,6 +94,9 @@ type (
 		params    url.Values
 		needClose bool
 		res       resource.Resource
+
+		multipart    *MultipartForm
+		multipartErr error
 	}
 )
 
@@ -93,6 +123,10 @@ func (req *request) init(e Environment, r resource.Resource, requ *http.Request,
 	return req
 }
 
+func (req *request) raw() *request {
+	return req
+}
+
 func (req *request) destroy() error {
 	req.Attrs.Clear()
 	req.Environment = nil
@@ -102,9 +136,17 @@ func (req *request) destroy() error {
 	req.params = nil
 
 	var err error
+	if req.multipart != nil {
+		err = req.multipart.RemoveAll()
+		req.multipart = nil
+	}
+	req.multipartErr = nil
+
 	if req.needClose {
 		req.needClose = false
-		err = req.request.Body.Close()
+		if e := req.request.Body.Close(); err == nil {
+			err = e
+		}
 	}
 	req.request = nil
 	req.res = nil
@@ -112,10 +154,16 @@ func (req *request) destroy() error {
 	return err
 }
 
+// Wrap replace the underlying request, method resolved from X-HTTP-Method-Override
+// is kept unless the wrapper change method of request, wrappers such as body limiter
+// only replace the body
 func (req *request) Wrap(fn RequestWrapper) {
+	method := req.request.Method
 	req.request, req.needClose = fn(req.request, req.needClose)
 	req.header = req.request.Header
-	req.method = req.request.Method
+	if req.request.Method != method {
+		req.method = strings.ToUpper(req.request.Method)
+	}
 }
 
 func (req *request) Read(data []byte) (int, error) {
@@ -161,8 +209,9 @@ func (req *request) Param(name string) (value string) {
 	return
 }
 
-// Params return request parameters with name,
-// it only get params of url query, don't parse request body
+// Params return request parameters with name, for GET/HEAD/OPTIONS, it's params
+// of url query, otherwise it's params of request body, multipart request is parsed
+// by Multipart with default option
 func (req *request) Params(name string) []string {
 	params, request := req.params, req.request
 	if params == nil {
@@ -170,6 +219,17 @@ func (req *request) Params(name string) []string {
 		case GET, HEAD, OPTIONS:
 			params = request.URL.Query()
 		default:
+			if isMultipart(request) {
+				form, err := req.Multipart(nil)
+				if err == nil {
+					params = form.Values
+				} else {
+					params = emptyParams
+					req.Logger().Warnln(err)
+				}
+				break
+			}
+
 			err := request.ParseForm()
 			if err == nil {
 				params = request.PostForm
@@ -238,10 +298,80 @@ func (req *request) Header(name string) string {
 	return req.header.Get(name)
 }
 
+// Receive decode request body to v through resource choosed by Content-Type, then
+// validate it by struct tags, see Validate. If Content-Type is not supported,
+// ErrUnsupportedMedia is returned.
 func (req *request) Receive(v interface{}) error {
-	if req.res == nil {
-		return ErrNoResourceType
+	if err := req.receive(v); err != nil {
+		return err
+	}
+
+	return Validate(v)
+}
+
+func (req *request) receive(v interface{}) error {
+	res, err := req.contentResource()
+	if err != nil {
+		return err
+	}
+
+	return res.Receive(req, v)
+}
+
+// contentResource return the resource to decode request body, it's choosed by
+// Content-Type, independent of the resource for response which is choosed by
+// Accept. Type with structured suffix such as "application/merge-patch+json" is
+// decoded as "application/json". If Content-Type is empty, default resource is
+// used, if there is no default resource, the response resource is used.
+func (req *request) contentResource() (resource.Resource, error) {
+	m := req.ResourceMaster()
+	typ := req.Header(HEADER_CONTENTTYPE)
+	if typ == "" {
+		if res := findResource(m, m.Default); res != nil {
+			return res, nil
+		}
+		if req.res == nil {
+			return nil, ErrNoResourceType
+		}
+
+		return req.res, nil
+	}
+
+	typ, _, err := mime.ParseMediaType(typ)
+	if err != nil {
+		return nil, ErrUnsupportedMedia
+	}
+	res := findResource(m, typ)
+	if res == nil {
+		res = findResource(m, suffixType(typ))
+	}
+	if res == nil {
+		return nil, ErrUnsupportedMedia
+	}
+
+	return res, nil
+}
+
+// suffixType return the media type of structured syntax suffix, such as
+// application/json for application/vnd.api+json, empty if there is no suffix
+func suffixType(typ string) string {
+	slash, plus := strings.IndexByte(typ, '/'), strings.LastIndexByte(typ, '+')
+	if slash < 0 || plus < slash {
+		return ""
+	}
+
+	return typ[:slash+1] + typ[plus+1:]
+}
+
+func findResource(m *resource.Master, typ string) resource.Resource {
+	if typ == "" {
+		return nil
+	}
+	for i, t := range m.Types {
+		if t == typ {
+			return m.Resources[i]
+		}
 	}
 
-	return req.res.Receive(req, v)
+	return nil
 }
@@ -39,8 +39,16 @@ type (
 
 		SetAdvancedCookie(c *http.Cookie)
 		SetCookie(name, value string, lifetime int)
+		// SetSignedCookie/SetEncryptedCookie sign/encrypt value by server's CookieCodec
+		SetSignedCookie(c *http.Cookie, value interface{}) error
+		SetEncryptedCookie(c *http.Cookie, value interface{}) error
 		DeleteClientCookie(name string)
 
+		// SetETag/SetLastModified set version of resource, it should be set on
+		// successful writes for the next conditional request
+		SetETag(etag string)
+		SetLastModified(t time.Time)
+
 		CacheSeconds(secs int)
 		CacheUntil(*time.Time)
 		NoCache()
@@ -67,10 +75,17 @@ type (
 		Value() interface{}
 		SetValue(interface{})
 
+		// ServeContent serve a seekable content, range requests, conditional
+		// requests and HEAD request are handled
+		ServeContent(req Request, name string, modTime time.Time, content io.ReadSeeker, opt *ContentOption)
+
 		Resource() resource.Resource
 		// Send send marshaled value to client
 		Send(string, interface{}) error
 
+		// raw return the underlying response, it's promoted to wrappers which
+		// embed a Response
+		raw() *response
 		destroy() error
 	}
 
@@ -106,6 +121,10 @@ func (resp *response) init(env Environment, r resource.Resource, w http.Response
 	return resp
 }
 
+func (resp *response) raw() *response {
+	return resp
+}
+
 func (resp *response) destroy() error {
 	resp.flushHeader()
 	resp.statusWrited = false
@@ -178,8 +197,9 @@ func (resp *response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return hijacker.Hijack()
 }
 
-// Flush flush response's output
+// Flush flush response's output, status and header are written if not
 func (resp *response) Flush() {
+	resp.flushHeader()
 	if flusher, is := resp.ResponseWriter.(http.Flusher); is {
 		flusher.Flush()
 	}
@@ -225,8 +245,12 @@ func (resp *response) NoCache() {
 	resp.SetHeader(HEADER_CACHECONTROL, "no-cache")
 }
 
+// SetAdvancedCookie setup response cookie, unset Path, Domain and SameSite are
+// filled by the configuration of server's CookieCodec
 func (resp *response) SetAdvancedCookie(c *http.Cookie) {
-	resp.AddHeader(HEADER_SETCOOKIE, c.String())
+	cookie := *c
+	resp.env.Server().Cookies.fillCookie(&cookie)
+	resp.AddHeader(HEADER_SETCOOKIE, cookie.String())
 }
 
 // SetCookie setup response cookie
@@ -0,0 +1,201 @@
+package zerver
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+type (
+	// ResponseBuffer hold status, headers and body of response until it's committed,
+	// so filter can inspect or rewrite them after the chain returns. If body exceed
+	// the limit, or response is flushed such as streaming, buffered data is committed
+	// and the rest is written directly, Buffering report whether it still buffering.
+	//
+	//	buf := zerver.BufferResponse(resp, 0)
+	//	chain(req, resp)
+	//	if buf.Buffering() {
+	//		buf.SetBody(transform(buf.Body()))
+	//	}
+	ResponseBuffer struct {
+		resp      *response
+		w         *bufferedWriter
+		limit     int
+		body      bytes.Buffer
+		committed bool
+		discard   bool
+		onHeader  func(*ResponseBuffer) bool
+	}
+
+	bufferedWriter struct {
+		http.ResponseWriter
+		needClose bool
+		buf       *ResponseBuffer
+	}
+)
+
+// BufferResponse enable buffered mode of response by Response.Wrap, the limit of
+// buffered body is default 1M if it's not positive. Buffered response is committed
+// when response is destroyed, or by Commit.
+func BufferResponse(resp Response, limit int) *ResponseBuffer {
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	b := &ResponseBuffer{
+		resp:  resp.raw(),
+		limit: limit,
+	}
+	resp.Wrap(func(w http.ResponseWriter, needClose bool) (http.ResponseWriter, bool) {
+		b.w = &bufferedWriter{
+			ResponseWriter: w,
+			needClose:      needClose,
+			buf:            b,
+		}
+		return b.w, true
+	})
+
+	return b
+}
+
+// Buffering return whether response is still buffered, after it's committed,
+// status, headers and body can't be changed
+func (b *ResponseBuffer) Buffering() bool {
+	return !b.committed
+}
+
+// Status return status of response
+func (b *ResponseBuffer) Status() int {
+	return b.resp.status
+}
+
+// SetStatus change status of response even if handler has written
+func (b *ResponseBuffer) SetStatus(status int) {
+	if !b.committed {
+		b.resp.status = status
+	}
+}
+
+// Header return headers of response, they can be changed before commit
+func (b *ResponseBuffer) Header() http.Header {
+	return b.w.Header()
+}
+
+// Body return buffered body, it should not be retained
+func (b *ResponseBuffer) Body() []byte {
+	return b.body.Bytes()
+}
+
+// SetBody replace buffered body
+func (b *ResponseBuffer) SetBody(body []byte) {
+	if !b.committed {
+		b.body.Reset()
+		b.body.Write(body)
+	}
+}
+
+// OnHeader set a function called once when handler write headers, it's the first
+// Write, Flush or response destroy. If it return false, response is committed and
+// the rest is written directly, it's useful to skip buffering by status or headers.
+func (b *ResponseBuffer) OnHeader(fn func(*ResponseBuffer) bool) {
+	b.onHeader = fn
+}
+
+// Discard drop buffered body and the rest written by handler, it's used for
+// response without body such as 304
+func (b *ResponseBuffer) Discard() {
+	if !b.committed {
+		b.discard = true
+		b.body.Reset()
+	}
+}
+
+// Commit write status, headers and buffered body, the rest data will be written
+// directly. Content-Length is updated to length of body if it exists.
+func (b *ResponseBuffer) Commit() error {
+	return b.commit(true)
+}
+
+// commit write buffered response, if it's not final such as exceed the limit,
+// Content-Length is not changed
+func (b *ResponseBuffer) commit(final bool) error {
+	if b.committed {
+		return nil
+	}
+	b.committed = true
+
+	header := b.w.Header()
+	if final && header.Get(HEADER_CONTENTLENGTH) != "" {
+		header.Set(HEADER_CONTENTLENGTH, strconv.Itoa(b.body.Len()))
+	}
+	b.w.ResponseWriter.WriteHeader(b.resp.status)
+	if b.body.Len() == 0 {
+		return nil
+	}
+	_, err := b.w.ResponseWriter.Write(b.body.Bytes())
+	b.body.Reset()
+
+	return err
+}
+
+// WriteHeader call the header function, status is kept by response and written
+// on commit
+func (w *bufferedWriter) WriteHeader(int) {
+	b := w.buf
+	if fn := b.onHeader; fn != nil && !b.committed {
+		b.onHeader = nil
+		if !fn(b) {
+			b.commit(false)
+		}
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	b := w.buf
+	if b.discard {
+		return len(data), nil
+	}
+	if !b.committed {
+		if b.body.Len()+len(data) <= b.limit {
+			return b.body.Write(data)
+		}
+		if err := b.commit(false); err != nil {
+			return 0, err
+		}
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush commit buffered response, response is treated as streaming
+func (w *bufferedWriter) Flush() {
+	if w.buf.commit(false) != nil {
+		return
+	}
+	if f, is := w.ResponseWriter.(http.Flusher); is {
+		f.Flush()
+	}
+}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, is := w.ResponseWriter.(http.Hijacker)
+	if !is {
+		return nil, nil, ErrHijack
+	}
+
+	w.buf.committed = true
+	return hijacker.Hijack()
+}
+
+func (w *bufferedWriter) Close() error {
+	err := w.buf.commit(true)
+	if w.needClose {
+		if e := w.ResponseWriter.(io.Closer).Close(); err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,77 @@
+package zerver
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+func TestResponseBuffer(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+
+	var buffering bool
+	upper := func(req Request, resp Response, chain FilterChain) {
+		var buf *ResponseBuffer
+		if req.Method() == PATCH {
+			buf = BufferResponse(testWrappedResponse{resp}, 8)
+		} else {
+			buf = BufferResponse(resp, 8)
+		}
+		chain(req, resp)
+
+		buffering = buf.Buffering()
+		if buffering {
+			buf.SetStatus(http.StatusCreated)
+			buf.Header().Set("X-Length", "5")
+			buf.SetBody(bytes.ToUpper(buf.Body()))
+		}
+	}
+	tt.Nil(s.Handle("/", MapHandler{
+		GET: Intercept(func(req Request, resp Response) {
+			resp.SetHeader(HEADER_CONTENTLENGTH, "5")
+			resp.WriteString("hello")
+		}, FilterFunc(upper)),
+		POST: Intercept(func(req Request, resp Response) {
+			resp.WriteString("hello")
+			resp.WriteString(strings.Repeat("x", 8))
+		}, FilterFunc(upper)),
+		PUT: Intercept(func(req Request, resp Response) {
+			resp.WriteString("hello")
+			resp.Flush()
+		}, FilterFunc(upper)),
+		PATCH: Intercept(func(req Request, resp Response) {
+			resp.WriteString("hello")
+		}, FilterFunc(upper)),
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(method string) (*MockWriter, string) {
+		w, body := serveTest(s, method, "/", nil, nil)
+		return w, body.String()
+	}
+
+	w, body := serve(GET)
+	tt.True(buffering)
+	tt.Eq(http.StatusCreated, w.Status)
+	tt.Eq("5", w.Headers.Get("X-Length"))
+	tt.Eq("HELLO", body)
+
+	w, body = serve(POST) // exceed the limit
+	tt.False(buffering)
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq("helloxxxxxxxx", body)
+
+	_, body = serve(PUT) // streaming
+	tt.False(buffering)
+	tt.Eq("hello", body)
+
+	w, body = serve(PATCH) // wrapped response
+	tt.True(buffering)
+	tt.Eq(http.StatusCreated, w.Status)
+	tt.Eq("HELLO", body)
+}
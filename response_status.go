@@ -41,6 +41,8 @@ type StatusResponse interface {
 	ReportRequestedRangeNotSatisfiable() // 416
 	ReportExpectationFailed()            // 417
 	ReportTeapot()                       // 418
+	ReportUnprocessableEntity()          // 422
+	ReportPreconditionRequired()         // 428
 
 	ReportInternalServerError()     // 500
 	ReportNotImplemented()          // 501
@@ -225,6 +227,16 @@ func (resp *response) ReportTeapot() {
 	resp.ReportStatus(http.StatusTeapot)
 }
 
+//422
+func (resp *response) ReportUnprocessableEntity() {
+	resp.ReportStatus(http.StatusUnprocessableEntity)
+}
+
+//428
+func (resp *response) ReportPreconditionRequired() {
+	resp.ReportStatus(http.StatusPreconditionRequired)
+}
+
 //500
 func (resp *response) ReportInternalServerError() {
 	resp.ReportStatus(http.StatusInternalServerError)
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/cosiner/gohper/errors"
 	"github.com/cosiner/gohper/runtime2"
@@ -19,7 +20,9 @@ type (
 
 		PrintRouteTree(w io.Writer)
 
-		Group(prefix string, fn func(Router))
+		// Group create a group router for given prefix, filters will be applied to
+		// handlers registered through it, the group can be nested
+		Group(prefix string, fn func(Router), filters ...interface{})
 		HandleFunc(pattern string, method string, handler HandleFunc) error
 		Handle(pattern string, handler interface{}) error
 
@@ -38,12 +41,22 @@ type (
 		MatchWebSocketHandler(url *url.URL) (WebSocketHandler, URLVarIndexer)
 		// MatchTaskHandler
 		MatchTaskHandler(url *url.URL) TaskHandler
+
+		// Routes return all handler routes and their metadata
+		Routes() []Route
+	}
+
+	// RouteDescriber describe the handler of a route, the description will be
+	// printed after the route in route tree
+	RouteDescriber interface {
+		DescribeRoute() string
 	}
 
 	routeProcessor struct {
 		handlerPattern string
 		handlerVars    map[string]int
 		handler        Handler
+		handlerMeta    RouteMeta
 
 		wsHandlerPattern string
 		wsHandlerVars    map[string]int
@@ -58,11 +71,18 @@ type (
 	// router is a actual url router, it only process path of url, other section is
 	// not mentioned
 	router struct {
-		str      string    // path section hold by current route node
-		chars    []byte    // all possible first characters of next route node
-		childs   []*router // child routers
-		noFilter bool
+		str    string    // path section hold by current route node
+		chars  []byte    // all possible first characters of next route node
+		childs []*router // child routers
+		parent *router   // parent router, it's nil for root
 		routeProcessor
+
+		// these fields are computed by build after route tree changed
+		chain   []Filter           // filters of all parent nodes and current node
+		varsLen int                // count of path variables from root to current node
+		indexer *urlVarIndexer     // shared indexer for static route, it has no values
+		static  map[string]*router // only for root, static routes without variable
+		build   sync.Once          // only for root, reset after route tree changed
 	}
 
 	existError struct {
@@ -77,6 +97,7 @@ const (
 		" or catchall at the same position, " +
 		"this means one of them will nerver be matched, " +
 		"please check your routes")
+	ErrConflictVarName = errors.Err("Handlers of same route must use same path variable names")
 )
 
 func (e existError) Error() string {
@@ -85,10 +106,7 @@ func (e existError) Error() string {
 
 // NewRouter create a new Router
 func NewRouter() Router {
-	rt := new(router)
-	rt.noFilter = true
-
-	return rt
+	return new(router)
 }
 
 func (*router) reportExistError(typ, pattern string) error {
@@ -100,7 +118,13 @@ func (*router) reportExistError(typ, pattern string) error {
 }
 
 // Init init all handlers, filters, websocket handlers in route tree
-func (rt *router) Init(env Environment) (err error) {
+func (rt *router) Init(env Environment) error {
+	rt.build.Do(rt.buildTree)
+
+	return rt.init(env)
+}
+
+func (rt *router) init(env Environment) (err error) {
 	if rt.handler != nil {
 		err = rt.handler.Init(env)
 	}
@@ -118,7 +142,7 @@ func (rt *router) Init(env Environment) (err error) {
 	}
 
 	for i := 0; i < len(rt.childs) && err == nil; i++ {
-		err = rt.childs[i].Init(env)
+		err = rt.childs[i].init(env)
 	}
 
 	return
@@ -172,8 +196,9 @@ func (rt *router) Patch(pattern string, handler HandleFunc) error {
 	return rt.HandleFunc(pattern, PATCH, handler)
 }
 
-func (rt *router) Group(prefix string, fn func(Router)) {
-	fn(NewGroupRouter(rt, prefix))
+// Group create a group router with given prefix and filters
+func (rt *router) Group(prefix string, fn func(Router), filters ...interface{}) {
+	fn(NewGroupRouter(rt, prefix, filters...))
 }
 
 // HandleFunc add HandleFunc to router for given pattern and method
@@ -207,12 +232,17 @@ func (rt *router) HandleFunc(pattern, method string, handler HandleFunc) error {
 //
 // to router for given pattern
 //
+// Only one Handler is allowed per route, unless they are created by When with
+// conditions, then they will be chosen by conditions after path matched.
+//
 // TaskHandler, Router, Filter will not catch url variable values.
 func (rt *router) Handle(pattern string, handler interface{}) error {
 	if handler == nil || pattern == "" {
 		log.Panicln("Nil handler or empty pattern is not allowed")
 	}
 
+	rt.invalidate()
+
 	routePath, pathVars := compile(pattern)
 	if r, is := handler.(*router); is {
 		if !rt.addPathRouter(routePath, r) {
@@ -227,21 +257,23 @@ func (rt *router) Handle(pattern string, handler interface{}) error {
 		return ErrConflictPathVar
 	}
 
-	if h := convertHandler(handler); h != nil {
-		if nrt.handler != nil {
-			return nrt.reportExistError("Handler", pattern)
+	if m, is := handler.(metaHandler); is {
+		if m.handler != nil {
+			if err := nrt.setHandler(m.handler, pattern, pathVars); err != nil {
+				return err
+			}
 		}
-
-		nrt.handler = h
-		nrt.handlerVars = pathVars
-		nrt.handlerPattern = pattern
+		nrt.handlerMeta = append(nrt.handlerMeta, m.meta...)
 
 		return nil
 	}
 
+	if h := convertHandler(handler); h != nil {
+		return nrt.setHandler(h, pattern, pathVars)
+	}
+
 	if f := convertFilter(handler); f != nil {
-		rt.noFilter = false
-		nrt.filters = append(nrt.filters, convertFilter(f))
+		nrt.filters = append(nrt.filters, f)
 
 		return nil
 	}
@@ -273,21 +305,62 @@ func (rt *router) Handle(pattern string, handler interface{}) error {
 	return nil
 }
 
+// setHandler set handler of route node, if there is a handler already, try to
+// merge them as conditional handlers
+func (rt *router) setHandler(h Handler, pattern string, pathVars map[string]int) error {
+	if rt.handler != nil {
+		if !sameVars(rt.handlerVars, pathVars) {
+			return ErrConflictVarName
+		}
+		if h = mergeConditionHandler(rt.handler, h); h == nil {
+			return rt.reportExistError("Handler", pattern)
+		}
+
+		rt.handler = h
+		return nil
+	}
+
+	if c, is := h.(conditionalHandler); is {
+		h = newConditionRoute(c)
+	}
+
+	rt.handler = h
+	rt.handlerVars = pathVars
+	rt.handlerPattern = pattern
+
+	return nil
+}
+
+// sameVars check whether two routes have same path variable names at same positions
+func sameVars(vars1, vars2 map[string]int) bool {
+	if len(vars1) != len(vars2) {
+		return false
+	}
+	for name, index := range vars1 {
+		if i, has := vars2[name]; !has || i != index {
+			return false
+		}
+	}
+
+	return true
+}
+
 // MatchWebSockethandler match url to find final websocket handler
 func (rt *router) MatchWebSocketHandler(url *url.URL) (WebSocketHandler, URLVarIndexer) {
-	path := url.Path
+	rt.build.Do(rt.buildTree)
+
 	indexer := newVarIndexerFromPool()
-	rt, values := rt.matchOne(path, indexer.values)
+	n, _, values := rt.find(url.Path, indexer.values)
 	indexer.values = values
 
-	if rt == nil || rt.wsHandler == nil {
+	if n == nil || n.wsHandler == nil {
 		return nil, indexer
 	}
 
-	indexer.vars = rt.wsHandlerVars
-	indexer.pattern = rt.wsHandlerPattern
+	indexer.vars = n.wsHandlerVars
+	indexer.pattern = n.wsHandlerPattern
 
-	return rt.wsHandler, indexer
+	return n.wsHandler, indexer
 }
 
 // MatchTaskhandler match url to find final task handler
@@ -299,54 +372,99 @@ func (rt *router) MatchTaskHandler(url *url.URL) TaskHandler {
 	return rt.taskHandler
 }
 
-// // MatchHandler match url to find final websocket handler
-// func (rt *router) MatchHandler(url *url.URL) (handler Handler, indexer URLVarIndexer) {
-//  path := url.Path
-//  indexer = newVarIndexerFromPool()
-//  rt, values := rt.matchOne(path, indexer.values)
-//  indexer.values = values
-//  if rt != nil && rt.processor != nil {
-//      if hp := p.handler; hp != nil {
-//          indexer.vars = hp.vars
-//          handler = hp.handler
-//      }
-//  }
-//  return
-// }
-
-// MatchHandlerFilters match url to fin final handler and each filters
+// MatchHandlerFilters match url to fin final handler and each filters.
+//
+// Static routes are found by map lookup and share a precomputed indexer, it
+// don't allocate anything. Filters are precomputed for each route node, the
+// returned filters should not be modified.
 func (rt *router) MatchHandlerFilters(url *url.URL) (Handler, URLVarIndexer, []Filter) {
-	var (
-		path    = url.Path
-		indexer = newVarIndexerFromPool()
-		values  = indexer.values
-		filters []Filter
-	)
+	rt.build.Do(rt.buildTree)
 
-	if rt.noFilter {
-		rt, values = rt.matchOne(path, indexer.values)
-	} else {
-		pathIndex, continu := 0, true
-		for continu {
-			if fs := rt.filters; len(fs) != 0 {
-				if filters == nil {
-					filters = newFiltersFromPool()
-				}
-				filters = append(filters, fs...)
-			}
-			pathIndex, values, rt, continu = rt.matchMultiple(path, pathIndex, values)
-		}
+	path := url.Path
+	if n := rt.static[path]; n != nil {
+		return n.handler, n.indexer, n.chain
 	}
+
+	indexer := newVarIndexerFromPool()
+	n, last, values := rt.find(path, indexer.values)
 	indexer.values = values
 
-	if rt == nil || rt.handler == nil {
+	var filters []Filter
+	if last != nil {
+		filters = last.chain
+	}
+
+	if n == nil || n.handler == nil {
 		return nil, indexer, filters
 	}
 
-	indexer.vars = rt.handlerVars
-	indexer.pattern = rt.handlerPattern
+	indexer.vars = n.handlerVars
+	indexer.pattern = n.handlerPattern
+	indexer.meta = n.handlerMeta
+
+	return n.handler, indexer, filters
+}
+
+// find find the route node for path, the first returned node is the final matched
+// node, the second is the last node whose section is full matched, it's used to
+// find filters when final node is not found
+func (rt *router) find(path string, values []string) (*router, *router, []string) {
+	if n := rt.static[path]; n != nil {
+		return n, n, values
+	}
 
-	return rt.handler, indexer, filters
+	return rt.match(path, values)
+}
+
+// buildTree precompute filters and variables count of each node, and collect
+// static routes, it's called once by Init or the first match after route tree
+// changed. Routes should not be changed while serving.
+func (rt *router) buildTree() {
+	rt.static = make(map[string]*router)
+	rt.buildNode(rt, "", nil, 0)
+}
+
+// invalidate reset build state of the root router of route tree, the tree will
+// be rebuilt by next Init or match
+func (rt *router) invalidate() {
+	for rt.parent != nil {
+		rt = rt.parent
+	}
+	rt.build = sync.Once{}
+}
+
+func (rt *router) buildNode(root *router, parentPath string, parentChain []Filter, varsLen int) {
+	path := parentPath + rt.str
+
+	rt.chain = parentChain
+	if len(rt.filters) != 0 {
+		rt.chain = make([]Filter, len(parentChain)+len(rt.filters))
+		copy(rt.chain, parentChain)
+		copy(rt.chain[len(parentChain):], rt.filters)
+	}
+
+	for i := 0; i < len(rt.str); i++ {
+		if rt.str[i] >= _WILDCARD {
+			varsLen++
+		}
+	}
+	rt.varsLen = varsLen
+
+	rt.indexer = nil
+	if varsLen == 0 && (rt.handler != nil || rt.wsHandler != nil ||
+		rt.taskHandler != nil || len(rt.filters) != 0) {
+		root.static[path] = rt
+		rt.indexer = &urlVarIndexer{
+			pattern: rt.handlerPattern,
+			meta:    rt.handlerMeta,
+			vars:    rt.handlerVars,
+			static:  true,
+		}
+	}
+
+	for _, c := range rt.childs {
+		c.buildNode(root, path, rt.chain, varsLen)
+	}
 }
 
 // addPath add an new path to route, use given function to operate the final
@@ -442,6 +560,10 @@ func (rt *router) moveAllToChild(childStr string, newStr string) {
 		routeProcessor: rt.routeProcessor,
 	}
 
+	for _, c := range rnCopy.childs {
+		c.parent = rnCopy
+	}
+
 	rt.chars, rt.childs, rt.routeProcessor = nil, nil, routeProcessor{}
 	rt.addChild(childStr[0], rnCopy)
 	rt.str = newStr
@@ -463,6 +585,7 @@ func (rt *router) addChild(b byte, n *router) bool {
 	}
 	chars[l], childs[l] = b, n
 	rt.chars, rt.childs = chars, childs
+	n.parent = rt
 
 	return true
 }
@@ -479,63 +602,22 @@ const (
 	_PRINT_SEP = "-"
 )
 
-// matchMultiple match multi route node
-// returned value:(first:next path start index, second:if continue, it's next node to match,
-// else it's final match node, last:whether continu match)
-func (rt *router) matchMultiple(path string, pathIndex int, values []string) (int,
-	[]string, *router, bool) {
-	str, strIndex := rt.str, 0
-	strLen, pathLen := len(str), len(path)
-
-	for strIndex < strLen {
-		if pathIndex != pathLen {
-			c := str[strIndex]
-			strIndex++
-
-			switch c {
-			case path[pathIndex]: // else check character MatchPath or not
-				pathIndex++
-			case _WILDCARD:
-				// if read '*', MatchPath until next '/'
-				start := pathIndex
-				for pathIndex < pathLen && path[pathIndex] != '/' {
-					pathIndex++
-				}
-				values = append(values, path[start:pathIndex])
-			case _REMAINSALL: // parse end, full matched
-				values = append(values, path[pathIndex:pathLen])
-				pathIndex = pathLen
-				strIndex = strLen
-			default:
-				return -1, nil, nil, false // not matched
-			}
-		} else {
-			return -1, nil, nil, false // path parse end
-		}
-	}
-
-	if pathIndex != pathLen { // path not parse end, to find a child node to continue
-		p := path[pathIndex]
-		for i, c := range rt.chars {
-			if c == p || c >= _WILDCARD {
-				return pathIndex, values, rt.childs[i], true
-			}
-		}
-		rt = nil
-	}
-
-	return pathIndex, values, rt, false
-}
-
-// matchOne match one longest route node and return values of path variable
-func (rt *router) matchOne(path string, values []string) (*router, []string) {
+// match match the longest route node and return values of path variable,
+// the first returned node is the final matched node, the second is the last node
+// whose section is full matched
+func (rt *router) match(path string, values []string) (*router, *router, []string) {
 	var (
+		last               *router
 		str                string
 		strIndex, strLen   int
 		pathIndex, pathLen = 0, len(path)
 	)
 
 AGAIN:
+	if cap(values) < rt.varsLen { // grow to variables count of current route
+		values = append(make([]string, 0, rt.varsLen), values...)
+	}
+
 	str, strIndex = rt.str, 0
 	strLen = len(str)
 	for strIndex < strLen {
@@ -555,15 +637,16 @@ AGAIN:
 				values = append(values, path[start:pathIndex])
 			case _REMAINSALL: // parse end, full matched
 				values = append(values, path[pathIndex:pathLen])
-				return rt, values
+				return rt, rt, values
 			default:
-				return nil, nil // not matched
+				return nil, last, values // not matched
 			}
 		} else {
-			return nil, nil // path parse end
+			return nil, last, values // path parse end
 		}
 	}
 
+	last = rt
 	if pathIndex != pathLen { // path not parse end, must find a child node to continue
 		p := path[pathIndex]
 		for i, c := range rt.chars {
@@ -576,7 +659,7 @@ AGAIN:
 		rt = nil // child to parse
 	} /* else { path parse end, node is the last matched node }*/
 
-	return rt, values
+	return rt, last, values
 }
 
 // matchOnly match one longest route node without parameter values
@@ -712,6 +795,36 @@ func compile(path string) (newPath string, vars map[string]int) {
 	return
 }
 
+// decompile convert compiled path back to pattern, variable names are restored
+// from vars, unnamed variable will be empty
+func decompile(path string, vars map[string]int) string {
+	names := make(map[int]string, len(vars))
+	for name, index := range vars {
+		names[index] = name
+	}
+
+	var (
+		pattern  = make([]byte, 0, len(path)+len(vars)*4)
+		varIndex int
+	)
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case _WILDCARD, _REMAINSALL:
+			if c == _WILDCARD {
+				pattern = append(pattern, _MATCH_WILDCARD)
+			} else {
+				pattern = append(pattern, _MATCH_REMAINSALL)
+			}
+			pattern = append(pattern, names[varIndex]...)
+			varIndex++
+		default:
+			pattern = append(pattern, c)
+		}
+	}
+
+	return string(pattern)
+}
+
 // PrintRouteTree print an route tree
 // every level will be seperated by "-"
 func (rt *router) PrintRouteTree(w io.Writer) {
@@ -734,7 +847,17 @@ func (rt *router) printRouteTree(w io.Writer, parentPath string) {
 	}
 
 	cur := parentPath + string(s)
-	if _, e := w.Write(unsafe2.Bytes(cur + "\n")); e == nil {
+	line := cur
+	if d, is := rt.handler.(RouteDescriber); is {
+		if desc := d.DescribeRoute(); desc != "" {
+			line += " " + desc
+		}
+	}
+	if len(rt.handlerMeta) != 0 {
+		line += " meta: " + fmt.Sprint(rt.handlerMeta)
+	}
+
+	if _, e := w.Write(unsafe2.Bytes(line + "\n")); e == nil {
 		rt.accessAllChilds(func(n *router) bool {
 			n.printRouteTree(w, cur)
 			return true
@@ -742,6 +865,29 @@ func (rt *router) printRouteTree(w io.Writer, parentPath string) {
 	}
 }
 
+// Routes return all handler routes and their metadata, routes with only metadata
+// are also returned
+func (rt *router) Routes() []Route {
+	return rt.routes("", nil)
+}
+
+func (rt *router) routes(parentPath string, routes []Route) []Route {
+	path := parentPath + rt.str
+	if rt.handler != nil || len(rt.handlerMeta) != 0 {
+		routes = append(routes, Route{
+			Pattern: decompile(path, rt.handlerVars),
+			Handler: rt.handler,
+			Meta:    rt.handlerMeta,
+		})
+	}
+
+	for _, n := range rt.childs {
+		routes = n.routes(path, routes)
+	}
+
+	return routes
+}
+
 // accessAllChilds access all childs of node
 func (rt *router) accessAllChilds(fn func(*router) bool) {
 	for _, n := range rt.childs {
@@ -751,7 +897,7 @@ func (rt *router) accessAllChilds(fn func(*router) bool) {
 	}
 }
 
-func _tmpGetMapHandler(rt *router, pattern string) MapHandler {
+func _tmpGetMapHandler(rt interface{}, pattern string) MapHandler {
 	h := TmpHGet(rt, pattern)
 	if h == nil {
 		return nil
@@ -760,6 +906,6 @@ func _tmpGetMapHandler(rt *router, pattern string) MapHandler {
 	return h.(MapHandler)
 }
 
-func _tmpSetMapHandler(rt *router, pattern string, handler MapHandler) {
+func _tmpSetMapHandler(rt interface{}, pattern string, handler MapHandler) {
 	TmpHSet(rt, pattern, handler)
 }
@@ -0,0 +1,95 @@
+package host
+
+import (
+	"log"
+	"reflect"
+
+	"github.com/cosiner/gohper/reflect2"
+	"github.com/cosiner/zerver"
+)
+
+type (
+	// hostMatchers is sorted by pattern kinds, pattern rules are described by
+	// zerver.HostPattern
+	hostMatchers []*zerver.HostPattern
+
+	// hostVarIndexer add host variables to the url variable indexer of path,
+	// host variables will shadow path variables with same name
+	hostVarIndexer struct {
+		zerver.URLVarIndexer
+		pattern *zerver.HostPattern
+		values  []string
+	}
+)
+
+// add add a host pattern, return the index should be inserted for the value
+// related to this pattern, patterns are sorted by kind: exact, variable, wildcard,
+// and "*" at last, wildcards with more labels go first, others are sorted by
+// added order
+func (ms *hostMatchers) add(pattern string) int {
+	m := zerver.NewHostPattern(pattern)
+	for _, hm := range *ms {
+		if hm.String() == m.String() {
+			log.Panicln("Host pattern already exist: " + pattern)
+		}
+	}
+
+	matchers := *ms
+	l := len(matchers)
+	matchers = append(matchers, nil)
+	for ; l > 0 && m.Before(matchers[l-1]); l-- {
+		matchers[l] = matchers[l-1]
+	}
+	matchers[l] = m
+	*ms = matchers
+
+	return l
+}
+
+// match find first matched pattern for host of url, -1 returned if not found
+func (ms hostMatchers) match(urlHost string) (int, *zerver.HostPattern, []string) {
+	for i, m := range ms {
+		if values, matched := m.Match(urlHost); matched {
+			return i, m, values
+		}
+	}
+
+	return -1, nil, nil
+}
+
+// hostIndexer wrap indexer of path, add host variables to it
+func hostIndexer(m *zerver.HostPattern, indexer zerver.URLVarIndexer, values []string) zerver.URLVarIndexer {
+	if !m.HasVars() || indexer == nil {
+		return indexer
+	}
+
+	return &hostVarIndexer{
+		URLVarIndexer: indexer,
+		pattern:       m,
+		values:        values,
+	}
+}
+
+func (v *hostVarIndexer) URLVar(name string) string {
+	if index, has := v.pattern.VarIndex(name); has {
+		return v.values[index]
+	}
+
+	return v.URLVarIndexer.URLVar(name)
+}
+
+func (v *hostVarIndexer) URLVarDef(name string, defvalue string) string {
+	if index, has := v.pattern.VarIndex(name); has {
+		return v.values[index]
+	}
+
+	return v.URLVarIndexer.URLVarDef(name, defvalue)
+}
+
+func (v *hostVarIndexer) ScanURLVar(name string, addr interface{}) error {
+	if index, has := v.pattern.VarIndex(name); has {
+		return reflect2.UnmarshalPrimitive(v.values[index], reflect.ValueOf(addr))
+	}
+
+	return v.URLVarIndexer.ScanURLVar(name, addr)
+}
@@ -0,0 +1,64 @@
+package host
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/zerver"
+)
+
+func TestHostMatch(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var ms hostMatchers
+	ms.add("*")
+	ms.add("*.example.com")
+	ms.add(":tenant.api.example.com")
+	ms.add("api.example.com:8080")
+	ms.add("api.example.com")
+
+	for host, pattern := range map[string]string{
+		"api.example.com":          "api.example.com",
+		"API.example.com:80":       "api.example.com",
+		"api.example.com:8080":     "api.example.com:8080",
+		"abc.api.example.com:8000": ":tenant.api.example.com",
+		"a.b.api.example.com":      "*.example.com",
+		"www.example.com":          "*.example.com",
+		"example.com":              "*",
+		"":                         "*",
+	} {
+		i, m, _ := ms.match(host)
+		tt.True(i >= 0, host)
+		tt.Eq(pattern, m.String(), host)
+	}
+
+	_, _, values := ms.match("abc.api.example.com")
+	tt.DeepEq([]string{"abc"}, values)
+}
+
+func TestHostRouter(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	tenant := zerver.NewRouter()
+	tt.Nil(tenant.Get("/user/:id", zerver.EmptyHandlerFunc))
+	def := zerver.NewRouter()
+	tt.Nil(def.Get("/", zerver.EmptyHandlerFunc))
+
+	rt := NewRouter()
+	rt.AddRouter(":tenant.api.example.com", tenant)
+	rt.AddRouter("*", def)
+
+	h, indexer, _ := rt.MatchHandlerFilters(&url.URL{Host: "abc.api.example.com:8080", Path: "/user/123"})
+	tt.True(h != nil)
+	tt.Eq("abc", indexer.URLVar("tenant"))
+	tt.Eq("123", indexer.URLVar("id"))
+
+	h, _, _ = rt.MatchHandlerFilters(&url.URL{Host: "example.com", Path: "/"})
+	tt.True(h != nil)
+
+	rfs := NewRootFilters()
+	rfs.AddRootFilters("*.example.com", zerver.NewRootFilters([]zerver.Filter{zerver.FilterFunc(zerver.EmptyFilterFunc)}))
+	tt.Eq(1, len(rfs.Filters(&url.URL{Host: "www.example.com"})))
+	tt.Eq(0, len(rfs.Filters(&url.URL{Host: "www.example.org"})))
+}
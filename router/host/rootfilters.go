@@ -8,7 +8,7 @@ import (
 )
 
 type RootFilters struct {
-	hosts   []string
+	hosts   hostMatchers
 	filters []zerver.RootFilters
 }
 
@@ -16,14 +16,16 @@ func NewRootFilters() *RootFilters {
 	return &RootFilters{}
 }
 
+// AddRootFilters add root filters for host pattern, pattern rules is same as
+// Router.AddRouter
 func (r *RootFilters) AddRootFilters(host string, rfs zerver.RootFilters) {
-	l := len(r.hosts) + 1
+	i := r.hosts.add(host)
 
-	hosts, filters := make([]string, l), make([]zerver.RootFilters, l)
-	copy(hosts, r.hosts)
-	copy(filters, r.filters)
-	hosts[l], filters[l] = host, rfs
-	r.hosts, r.filters = hosts, filters
+	filters := make([]zerver.RootFilters, len(r.filters)+1)
+	copy(filters, r.filters[:i])
+	copy(filters[i+1:], r.filters[i:])
+	filters[i] = rfs
+	r.filters = filters
 }
 
 func (r *RootFilters) Init(env zerver.Environment) error {
@@ -41,11 +43,8 @@ func (r *RootFilters) Add(interface{}) {
 
 // Filters return all root filters
 func (r *RootFilters) Filters(url *url.URL) []zerver.Filter {
-	host, hosts := url.Host, r.hosts
-	for i := range hosts {
-		if hosts[i] == host {
-			return r.filters[i].Filters(url)
-		}
+	if i, _, _ := r.hosts.match(url.Host); i >= 0 {
+		return r.filters[i].Filters(url)
 	}
 
 	return nil
@@ -11,7 +11,7 @@ import (
 type (
 	Router struct {
 		zerver.Router
-		hosts   []string
+		hosts   hostMatchers
 		routers []zerver.Router
 	}
 )
@@ -20,27 +20,32 @@ func NewRouter() *Router {
 	return &Router{}
 }
 
+// AddRouter add a router for host pattern, the pattern can be an exact host such as
+// "api.example.com", or contains variable such as ":tenant.api.example.com", or
+// wildcard such as "*.example.com", "*" is the default router match every host.
+// Port is optional, if it's specified, only host with same port will be matched.
+//
+// Exact hosts will be matched first, then variable hosts, and wildcard hosts at last.
+// Host variables can be accessed through URLVarIndexer.
 func (r *Router) AddRouter(host string, rt zerver.Router) {
-	l := len(r.hosts) + 1
+	i := r.hosts.add(host)
 
-	hosts, routers := make([]string, l), make([]zerver.Router, l)
-	copy(hosts, r.hosts)
-	copy(routers, r.routers)
-	hosts[l], routers[l] = host, rt
-	r.hosts, r.routers = hosts, routers
+	routers := make([]zerver.Router, len(r.routers)+1)
+	copy(routers, r.routers[:i])
+	copy(routers[i+1:], r.routers[i:])
+	routers[i] = rt
+	r.routers = routers
 }
 
 // Implement RouterMatcher
 
-func (r *Router) match(url *url.URL) zerver.Router {
-	host, hosts := url.Host, r.hosts
-	for i := range hosts {
-		if hosts[i] == host {
-			return r.routers[i]
-		}
+func (r *Router) match(url *url.URL) (zerver.Router, *zerver.HostPattern, []string) {
+	i, m, values := r.hosts.match(url.Host)
+	if i < 0 {
+		return nil, nil, nil
 	}
 
-	return nil
+	return r.routers[i], m, values
 }
 
 // Init init handlers and filters, websocket handlers
@@ -63,8 +68,9 @@ func (r *Router) Destroy() {
 
 // MatchHandlerFilters match given url to find all matched filters and final handler
 func (r *Router) MatchHandlerFilters(url *url.URL) (handler zerver.Handler, indexer zerver.URLVarIndexer, filters []zerver.Filter) {
-	if router := r.match(url); router != nil {
+	if router, m, values := r.match(url); router != nil {
 		handler, indexer, filters = router.MatchHandlerFilters(url)
+		indexer = hostIndexer(m, indexer, values)
 	}
 
 	return
@@ -72,8 +78,9 @@ func (r *Router) MatchHandlerFilters(url *url.URL) (handler zerver.Handler, inde
 
 // MatchWebSocketHandler match given url to find a matched websocket handler
 func (r *Router) MatchWebSocketHandler(url *url.URL) (handler zerver.WebSocketHandler, indexer zerver.URLVarIndexer) {
-	if router := r.match(url); router != nil {
+	if router, m, values := r.match(url); router != nil {
 		handler, indexer = router.MatchWebSocketHandler(url)
+		indexer = hostIndexer(m, indexer, values)
 	}
 
 	return
@@ -81,13 +88,23 @@ func (r *Router) MatchWebSocketHandler(url *url.URL) (handler zerver.WebSocketHa
 
 // MatchTaskHandler
 func (r *Router) MatchTaskHandler(url *url.URL) (handler zerver.TaskHandler) {
-	if router := r.match(url); router != nil {
+	if router, _, _ := r.match(url); router != nil {
 		handler = router.MatchTaskHandler(url)
 	}
 
 	return
 }
 
+// Routes return routes of all hosts
+func (r *Router) Routes() []zerver.Route {
+	var routes []zerver.Route
+	for i := range r.routers {
+		routes = append(routes, r.routers[i].Routes()...)
+	}
+
+	return routes
+}
+
 type indentWriter struct {
 	io.Writer
 }
@@ -107,7 +124,7 @@ func (w indentWriter) Write(data []byte) (int, error) {
 
 func (r *Router) PrintRouteTree(w io.Writer) {
 	for i := range r.routers {
-		w.Write(unsafe2.Bytes(r.hosts[i] + "\n"))
+		w.Write(unsafe2.Bytes(r.hosts[i].String() + "\n"))
 		r.routers[i].PrintRouteTree(w)
 	}
 }
@@ -1,48 +1,230 @@
 package zerver
 
-type groupRouter struct {
-	prefix string
-	Router
-}
+type (
+	// groupRouter add prefix to all routes registered through it, if group
+	// filters exist, they will be applied to handlers registered through it,
+	// other routes with same prefix will not be affected
+	groupRouter struct {
+		prefix  string
+		filters *groupFilters
+		Router
+	}
 
-func NewGroupRouter(rt Router, prefix string) Router {
-	return groupRouter{
+	// groupFilters is filters of a group, it will be inited/destroyed only once
+	// no matter how many handlers are registered through the group
+	groupFilters struct {
+		filters   []Filter
+		inited    bool
+		destroyed bool
+	}
+
+	// groupHandler apply group filters to a handler, group filters will run after
+	// route filters, before handler. Handle functions of standard methods are
+	// wrapped once when inited
+	groupHandler struct {
+		handler  Handler
+		filters  *groupFilters
+		handlers map[string]HandleFunc
+	}
+)
+
+// groupMethods is methods whose handle functions are wrapped when group handler
+// inited, others are wrapped for each request
+var groupMethods = []string{GET, POST, DELETE, PUT, PATCH, HEAD, OPTIONS}
+
+// NewGroupRouter create a group router add prefix and filters to all routes
+// registered through it.
+//
+// Filters are only applied to Handler/HandleFunc, websocket handlers,
+// task handlers and filters registered through it are only prefixed, group
+// filters will not run for them.
+func NewGroupRouter(rt Router, prefix string, filters ...interface{}) Router {
+	gr := groupRouter{
 		prefix: prefix,
 		Router: rt,
 	}
+
+	if len(filters) != 0 {
+		fs := make([]Filter, len(filters))
+		for i, f := range filters {
+			fs[i] = panicConvertFilter(f)
+		}
+		gr.filters = &groupFilters{filters: fs}
+	}
+
+	return gr
+}
+
+// Group create a sub group, prefix and filters of it will be appended to
+// current group's
+func (gr groupRouter) Group(prefix string, fn func(Router), filters ...interface{}) {
+	fn(NewGroupRouter(gr, prefix, filters...))
 }
 
 // HandleFunc add a function handler, method are defined as constant string
 func (gr groupRouter) HandleFunc(pattern string, method string, handler HandleFunc) error {
-	return gr.Router.HandleFunc(gr.prefix+pattern, method, handler)
+	if gr.filters == nil {
+		return gr.Router.HandleFunc(gr.prefix+pattern, method, handler)
+	}
+
+	method = parseRequestMethod(method)
+
+	fHandler := _tmpGetMapHandler(gr.filters, pattern)
+	if fHandler != nil {
+		fHandler.setMethodHandler(method, handler)
+		return nil
+	}
+
+	fHandler = make(MapHandler)
+	fHandler.setMethodHandler(method, handler)
+	if err := gr.Handle(pattern, fHandler); err != nil {
+		return err
+	}
+
+	_tmpSetMapHandler(gr.filters, pattern, fHandler)
+
+	return nil
 }
 
 // Handle add a handler
 func (gr groupRouter) Handle(pattern string, handler interface{}) error {
+	if gr.filters != nil {
+		handler = gr.wrapHandler(handler)
+	}
+
 	return gr.Router.Handle(gr.prefix+pattern, handler)
 }
 
+// wrapHandler apply group filters to handler, handlers with metadata or conditions
+// are kept, only the inner handler is wrapped
+func (gr groupRouter) wrapHandler(handler interface{}) interface{} {
+	switch h := handler.(type) {
+	case metaHandler:
+		if h.handler != nil {
+			h.handler = gr.wrapHandler(h.handler).(Handler)
+		}
+		return h
+	case conditionalHandler:
+		h.handler = gr.newGroupHandler(h.handler)
+		return h
+	}
+
+	if h := convertHandler(handler); h != nil {
+		return gr.newGroupHandler(h)
+	}
+
+	return handler
+}
+
+func (gr groupRouter) newGroupHandler(h Handler) groupHandler {
+	return groupHandler{
+		handler:  h,
+		filters:  gr.filters,
+		handlers: make(map[string]HandleFunc, len(groupMethods)),
+	}
+}
+
 // Get register a function handler process GET request for given pattern
 func (gr groupRouter) Get(pattern string, handleFunc HandleFunc) error {
-	return gr.Router.Get(gr.prefix+pattern, handleFunc)
+	return gr.HandleFunc(pattern, GET, handleFunc)
 }
 
 // Post register a function handler process POST request for given pattern
 func (gr groupRouter) Post(pattern string, handleFunc HandleFunc) error {
-	return gr.Router.Post(gr.prefix+pattern, handleFunc)
+	return gr.HandleFunc(pattern, POST, handleFunc)
 }
 
 // Put register a function handler process PUT request for given pattern
 func (gr groupRouter) Put(pattern string, handleFunc HandleFunc) error {
-	return gr.Router.Put(gr.prefix+pattern, handleFunc)
+	return gr.HandleFunc(pattern, PUT, handleFunc)
 }
 
 // Delete register a function handler process DELETE request for given pattern
 func (gr groupRouter) Delete(pattern string, handleFunc HandleFunc) error {
-	return gr.Router.Delete(gr.prefix+pattern, handleFunc)
+	return gr.HandleFunc(pattern, DELETE, handleFunc)
 }
 
 // Patch register a function handler process PATCH request for given pattern
 func (gr groupRouter) Patch(pattern string, handleFunc HandleFunc) error {
-	return gr.Router.Patch(gr.prefix+pattern, handleFunc)
+	return gr.HandleFunc(pattern, PATCH, handleFunc)
+}
+
+func (gf *groupFilters) Init(env Environment) error {
+	if gf.inited {
+		return nil
+	}
+	gf.inited = true
+
+	var err error
+	for i := 0; i < len(gf.filters) && err == nil; i++ {
+		err = gf.filters[i].Init(env)
+	}
+
+	return err
+}
+
+func (gf *groupFilters) Destroy() {
+	if gf.destroyed {
+		return
+	}
+	gf.destroyed = true
+
+	for _, f := range gf.filters {
+		f.Destroy()
+	}
+}
+
+func (h groupHandler) Init(env Environment) error {
+	if err := h.filters.Init(env); err != nil {
+		return err
+	}
+	if err := h.handler.Init(env); err != nil {
+		return err
+	}
+
+	for _, method := range groupMethods {
+		if fn := h.handler.Handler(method); fn != nil {
+			h.handlers[method] = h.intercept(fn)
+		}
+	}
+
+	return nil
+}
+
+func (h groupHandler) Destroy() {
+	h.handler.Destroy()
+	h.filters.Destroy()
+}
+
+// Handler return the method handle function wrapped by group filters
+func (h groupHandler) Handler(method string) HandleFunc {
+	if fn, has := h.handlers[method]; has {
+		return fn
+	}
+
+	fn := h.handler.Handler(method)
+	if fn == nil {
+		return nil
+	}
+
+	return h.intercept(fn)
+}
+
+// intercept wrap handle function by group filters, the result can be reused
+func (h groupHandler) intercept(fn HandleFunc) HandleFunc {
+	filters := h.filters.filters
+	for i := len(filters) - 1; i >= 0; i-- {
+		fn = newInterceptor(fn, filters[i])
+	}
+
+	return fn
+}
+
+// DescribeRoute forward the description of wrapped handler
+func (h groupHandler) DescribeRoute() string {
+	if d, is := h.handler.(RouteDescriber); is {
+		return d.DescribeRoute()
+	}
+
+	return ""
 }
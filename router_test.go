@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cosiner/gohper/errors"
@@ -289,14 +290,7 @@ func BenchmarkMatchRouteOne(b *testing.B) {
 	// path := "/user/keys"
 	// path := "/user/aa/exist"
 	for i := 0; i < b.N; i++ {
-		// pathIndex := 0
-		// var vars []string
-		// var continu = true
-		// n := r
-		// for continu {
-		// 	pathIndex, vars, n, continu = n.matchMulti(path, pathIndex, vars)
-		// }
-		_, _ = r.matchOne(path, make([]string, 0, 2))
+		_, _, _ = r.match(path, make([]string, 0, 2))
 	}
 }
 
@@ -307,19 +301,109 @@ func BenchmarkMatchRouteMultiple(b *testing.B) {
 	path := "/repos/cosiner/zerver/stargazers"
 	// path := "/user/aa/exist"
 	for i := 0; i < b.N; i++ {
-		pathIndex := 0
-		var vars = make([]string, 0, 2)
-		var continu = true
-		n := r
-		for continu {
-			pathIndex, vars, n, continu = n.matchMultiple(path, pathIndex, vars)
-		}
+		n, _, _ := r.match(path, make([]string, 0, 2))
 		if n == nil {
 			b.Fail()
 		}
 	}
 }
 
+// githubRouter register a handler to every node of the rt() route tree, node
+// for path "/repos/|/|/events" has route pattern "/repos/:/:/events"
+func githubRouter() *router {
+	rt := rt()
+	var setHandler func(*router) bool
+	setHandler = func(n *router) bool {
+		n.handler = MapHandler{GET: EmptyHandlerFunc}
+		n.accessAllChilds(setHandler)
+		return true
+	}
+	setHandler(rt)
+
+	return rt
+}
+
+// githubPaths return request paths of routes, variables are replaced by
+// "zerver", static only means skip routes with variables
+func githubPaths(rt *router, staticOnly bool) []string {
+	var paths []string
+	for _, route := range rt.Routes() {
+		if strings.Contains(route.Pattern, ":") {
+			if staticOnly {
+				continue
+			}
+			route.Pattern = strings.Replace(route.Pattern, ":", "zerver", -1)
+		}
+		paths = append(paths, route.Pattern)
+	}
+
+	return paths
+}
+
+var gr = githubRouter()
+
+func benchmarkMatchHandlerFilters(b *testing.B, path string) {
+	u := &url.URL{Path: path}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h, indexer, _ := gr.MatchHandlerFilters(u)
+		if h == nil {
+			b.Fail()
+		}
+		indexer.destroySelf()
+	}
+}
+
+func BenchmarkMatchStatic(b *testing.B) {
+	benchmarkMatchHandlerFilters(b, "/user/repos")
+}
+
+func BenchmarkMatchParam(b *testing.B) {
+	benchmarkMatchHandlerFilters(b, "/repos/cosiner/zerver/stargazers")
+}
+
+func BenchmarkMatchParams(b *testing.B) {
+	benchmarkMatchHandlerFilters(b, "/legacy/issues/search/aaa/bbb/ccc/ddd")
+}
+
+func BenchmarkMatchAll(b *testing.B) {
+	paths := githubPaths(gr, false)
+	urls := make([]*url.URL, len(paths))
+	for i, path := range paths {
+		urls[i] = &url.URL{Path: path}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, u := range urls {
+			_, indexer, _ := gr.MatchHandlerFilters(u)
+			indexer.destroySelf()
+		}
+	}
+}
+
+func TestMatchStaticNoAllocs(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	rt := githubRouter()
+	tt.Nil(rt.Handle("/user", EmptyFilterFunc))
+	for _, path := range githubPaths(rt, true) {
+		u := &url.URL{Path: path}
+		h, indexer, filters := rt.MatchHandlerFilters(u)
+		tt.True(h != nil, path)
+		tt.Eq(strings.HasPrefix(path, "/user"), len(filters) == 1, path)
+		indexer.destroySelf()
+
+		allocs := testing.AllocsPerRun(100, func() {
+			_, indexer, _ := rt.MatchHandlerFilters(u)
+			indexer.destroySelf()
+		})
+		tt.Eq(0.0, allocs, path)
+	}
+}
+
 func TestRoute(t *testing.T) {
 	rt := new(router)
 	errors.Fatal(rt.Handle("/user.:format", MapHandler{}))
@@ -328,9 +412,9 @@ func TestRoute(t *testing.T) {
 	// errors.Fatal(rt.Handle("/vba/:id", EmptyHandlerFunc))
 	// errors.Fatal(rt.Handle("/v0a/:id", EmptyHandlerFunc))
 	rt.PrintRouteTree(os.Stdout)
-	_, value := rt.matchOne("/user.json", nil)
+	_, _, value := rt.match("/user.json", nil)
 	t.Log(value)
-	rt, value = rt.matchOne("/vbc", nil)
+	rt, _, value = rt.match("/vbc", nil)
 	testing2.True(t, rt != nil)
 	t.Log(value)
 }
@@ -371,3 +455,120 @@ func TestSubRouter(t *testing.T) {
 	tt.True(rt.matchOnly("/user/info/123") != nil)
 	tt.True(rt.matchOnly("/bkko/info/123") == nil)
 }
+
+func TestSubRouterRebuild(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	userRt := NewRouter()
+	tt.Nil(userRt.HandleFunc("/info", GET, EmptyHandlerFunc))
+	rt := new(router)
+	tt.Nil(rt.Handle("/user", userRt))
+
+	match := func(path string) (Handler, []Filter) {
+		h, indexer, filters := rt.MatchHandlerFilters(&url.URL{Path: path})
+		indexer.destroySelf()
+		return h, filters
+	}
+
+	var wg sync.WaitGroup // route tree is built by first match
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, _ := match("/user/info")
+			tt.True(h != nil)
+		}()
+	}
+	wg.Wait()
+
+	// route tree is rebuilt after sub router changed
+	tt.Nil(userRt.HandleFunc("/posts", GET, EmptyHandlerFunc))
+	tt.Nil(userRt.Handle("/posts", EmptyFilterFunc))
+	h, filters := match("/user/posts")
+	tt.True(h != nil)
+	tt.Eq(1, len(filters))
+	tt.True(rt.static["/user/posts"] != nil)
+	h, _ = match("/user/info")
+	tt.True(h != nil)
+}
+
+func TestGroup(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var (
+		filters []string
+		filter  = func(name string) FilterFunc {
+			return func(req Request, resp Response, chain FilterChain) {
+				filters = append(filters, name)
+				chain(req, resp)
+			}
+		}
+		task string
+	)
+
+	rt := NewRouter()
+	rt.Group("/api", func(rt Router) {
+		tt.Nil(rt.Get("/user", EmptyHandlerFunc))
+		tt.Nil(rt.Post("/user", EmptyHandlerFunc))
+
+		rt.Group("/v1", func(rt Router) {
+			tt.Nil(rt.Get("/info", EmptyHandlerFunc))
+			tt.Nil(rt.Handle("/ws", func(WebSocketConn) {}))
+			tt.Nil(rt.Handle("/task", func(v interface{}) {
+				task = v.(string)
+			}))
+		}, filter("v1"))
+	}, filter("api"))
+	tt.Nil(rt.Get("/api/other", EmptyHandlerFunc))
+	tt.Nil(rt.Init(nil))
+
+	match := func(path, method string) []string {
+		filters = nil
+		h, _, _ := rt.MatchHandlerFilters(&url.URL{Path: path})
+		tt.True(h != nil)
+		h.Handler(method)(nil, nil)
+		return filters
+	}
+
+	tt.DeepEq([]string{"api"}, match("/api/user", GET))
+	tt.DeepEq([]string{"api"}, match("/api/user", POST))
+	tt.DeepEq([]string{"api", "v1"}, match("/api/v1/info", GET))
+	tt.True(match("/api/other", GET) == nil)
+
+	h, _, _ := rt.MatchHandlerFilters(&url.URL{Path: "/api/user"})
+	tt.True(h.Handler(DELETE) == nil)
+
+	// group filters are wrapped once when inited
+	h, _, _ = rt.MatchHandlerFilters(&url.URL{Path: "/api/v1/info"})
+	tt.Eq(0.0, testing.AllocsPerRun(100, func() {
+		h.Handler(GET)
+	}))
+
+	ws, _ := rt.MatchWebSocketHandler(&url.URL{Path: "/api/v1/ws"})
+	tt.True(ws != nil)
+
+	rt.MatchTaskHandler(&url.URL{Path: "/api/v1/task"}).Handle("task")
+	tt.Eq("task", task)
+}
+
+func TestRoutes(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	rt := NewRouter()
+	tt.Nil(rt.Get("/user/:id/info", EmptyHandlerFunc))
+	tt.Nil(rt.Handle("/file/*path", WithMeta(MapHandler{GET: EmptyHandlerFunc}, "file")))
+	rt.Group("/admin", func(rt Router) {
+		tt.Nil(rt.Handle("/log/:date", WithMeta(MapHandler{GET: EmptyHandlerFunc}, "admin")))
+	}, EmptyFilterFunc)
+	tt.Nil(rt.Handle("/user/:id/info", WithMeta(nil, "info")))
+
+	routes := make(map[string]RouteMeta)
+	for _, r := range rt.Routes() {
+		tt.NotNil(r.Handler)
+		routes[r.Pattern] = r.Meta
+	}
+	tt.Eq(3, len(routes))
+	tt.DeepEq(RouteMeta{"info"}, routes["/user/:id/info"])
+	tt.DeepEq(RouteMeta{"file"}, routes["/file/*path"])
+	tt.DeepEq(RouteMeta{"admin"}, routes["/admin/log/:date"])
+}
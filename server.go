@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -39,7 +40,8 @@ type (
 
 		// path variables count, suggest set as max or average, default 3
 		PathVarCount int
-		// filters count for each route, RootFilters is not include, default 5
+		// Deprecated: filters of each route are precomputed by router, it's not
+		// used any more
 		FilterCount int
 
 		// whether process user request or not when
@@ -71,6 +73,14 @@ type (
 		attrs.Attrs
 		RootFilters RootFilters // Match Every Routes
 		ResMaster   resource.Master
+		// Cookies sign and encrypt cookie values, keys should be setup before start
+		Cookies CookieCodec
+		// ErrorMapper convert errors returned by handlers to problems, errors it
+		// doesn't recognize are mapped by MapError
+		ErrorMapper ErrorMapper
+		// Fallback handle not found, method not allowed and not acceptable requests,
+		// fallbacks for hosts and path prefixes are set by SetFallback
+		Fallback Fallback
 		// public logger
 		Log log2.Logger
 		componentManager
@@ -78,11 +88,14 @@ type (
 		// server logger
 		log log2.Logger
 
+		fallbacks fallbacks
+
 		checker              websocket.HandshakeChecker
 		processNotAcceptable bool
 
 		listener    net.Listener
 		state       int32          // destroy or normal running
+		closing     chan struct{}  // closed when destroy
 		activeConns sync.WaitGroup // connections in service, don't include hijacked and websocket connections
 	}
 
@@ -132,9 +145,16 @@ func NewServerWith(rt Router, filters RootFilters) *Server {
 		RootFilters:      filters,
 		ResMaster:        resource.NewMaster(),
 		componentManager: newComponentManager(),
+		closing:          make(chan struct{}),
 	}
 }
 
+// Closing return a channel which will be closed when server start destroying,
+// long-lived handlers such as server-sent events should return on it
+func (s *Server) Closing() <-chan struct{} {
+	return s.closing
+}
+
 func (s *Server) Server() *Server {
 	return s
 }
@@ -179,35 +199,60 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, request *http.Request) {
 	if l := len(path); l > 1 && path[l-1] == '/' {
 		request.URL.Path = path[:l-1]
 	}
+	request.URL.Host = request.Host
 
 	if websocket.IsWebSocketRequest(request) {
-		s.serveWebSocket(w, request)
-	} else {
-		s.serveHTTP(w, request)
+		handler, indexer := s.MatchWebSocketHandler(request.URL)
+		if handler != nil {
+			s.serveWebSocket(w, request, handler, indexer)
+			return
+		}
+		// no websocket handler, it may be processed by normal handler such as proxy
+		if indexer != nil {
+			indexer.destroySelf()
+		}
 	}
+
+	s.serveHTTP(w, request)
 }
 
 // serveWebSocket serve for websocket protocal
-func (s *Server) serveWebSocket(w http.ResponseWriter, request *http.Request) {
-	handler, indexer := s.MatchWebSocketHandler(request.URL)
-	if handler == nil {
-		w.WriteHeader(http.StatusNotFound)
-	} else {
-		conn, err := websocket.UpgradeWebsocket(w, request, s.checker)
-		if err == nil {
-			handler.Handle(newWebSocketConn(s, conn, indexer))
-			indexer.destroySelf()
-		} // else connecion will be auto-closed when error occoured,
+func (s *Server) serveWebSocket(w http.ResponseWriter, request *http.Request,
+	handler WebSocketHandler, indexer URLVarIndexer) {
+	conn, err := websocket.UpgradeWebsocket(w, request, s.checker)
+	if err == nil {
+		handler.Handle(newWebSocketConn(s, conn, indexer))
+		indexer.destroySelf()
+	} // else connecion will be auto-closed when error occoured,
+}
+
+// acceptResource find resource of Accept header by ResMaster, if not found, the
+// structured syntax suffix of media types is tried, so vendor types such as
+// application/vnd.x+json;version=2 use the resource of application/json
+func (s *Server) acceptResource(accept string) (resource.Resource, string) {
+	if res, typ := s.ResMaster.Resource(accept); res != nil {
+		return res, typ
 	}
+
+	for _, typ := range strings.Split(accept, ",") {
+		if i := strings.IndexByte(typ, ';'); i >= 0 {
+			typ = typ[:i]
+		}
+		typ = suffixType(strings.ToLower(strings.TrimSpace(typ)))
+		if res := findResource(&s.ResMaster, typ); res != nil {
+			return res, typ
+		}
+	}
+
+	return nil, ""
 }
 
 // serveHTTP serve for http protocal
 func (s *Server) serveHTTP(w http.ResponseWriter, request *http.Request) {
 	url := request.URL
-	url.Host = request.Host
 	handler, indexer, filters := s.MatchHandlerFilters(url)
 
-	res, resType := s.ResMaster.Resource(request.Header.Get(HEADER_ACCEPT))
+	res, resType := s.acceptResource(request.Header.Get(HEADER_ACCEPT))
 
 	reqEnv := newRequestEnvFromPool()
 	req := reqEnv.req.init(s, res, request, indexer)
@@ -216,10 +261,13 @@ func (s *Server) serveHTTP(w http.ResponseWriter, request *http.Request) {
 	var chain FilterChain
 	if handler == nil {
 		resp.ReportNotFound()
+		chain = s.fallbackChain(request, _FALLBACK_NOTFOUND, resp, res, resType)
 	} else if res == nil && !s.processNotAcceptable {
 		resp.ReportNotAcceptable()
+		chain = s.fallbackChain(request, _FALLBACK_NOTACCEPTABLE, resp, res, resType)
 	} else if chain = FilterChain(handler.Handler(req.Method())); chain == nil {
 		resp.ReportMethodNotAllowed()
+		chain = s.fallbackChain(request, _FALLBACK_METHODNOTALLOWED, resp, res, resType)
 	} else {
 		resp.SetContentType(resType, res)
 	}
@@ -232,13 +280,29 @@ func (s *Server) serveHTTP(w http.ResponseWriter, request *http.Request) {
 	s.warnLog(resp.destroy())
 
 	recycleRequestEnv(reqEnv)
-	recycleFilters(filters)
+}
+
+// fallbackChain return the fallback handler as chain, content type is set to the
+// negotiated resource, or the default resource if it's not acceptable
+func (s *Server) fallbackChain(request *http.Request, kind int, resp Response, res resource.Resource, resType string) FilterChain {
+	fn := s.fallback(request, kind)
+	if fn == nil {
+		return nil
+	}
+
+	if res == nil {
+		res, resType = s.ResMaster.Resource(s.ResMaster.Default)
+	}
+	if res != nil {
+		resp.SetContentType(resType, res)
+	}
+
+	return FilterChain(fn)
 }
 
 func (o *ServerOption) init() {
 	defval.String(&o.ListenAddr, ":4000")
 	defval.Int(&o.PathVarCount, 3)
-	defval.Int(&o.FilterCount, 5)
 	if o.KeepAlivePeriod == 0 {
 		o.KeepAlivePeriod = 3 * time.Minute // same as net/http/server.go:tcpKeepAliveListener
 	}
@@ -279,8 +343,6 @@ func (s *Server) config(o *ServerOption) {
 
 	log("VarCountPerRoute:", o.PathVarCount)
 	pathVarCount = o.PathVarCount
-	log("FilterCountPerRoute:", o.FilterCount)
-	filterCount = o.FilterCount
 
 	s.componentManager.initHook = func(name string) {
 		switch name {
@@ -428,6 +490,7 @@ func (s *Server) Destroy(timeout time.Duration) bool {
 	if !atomic.CompareAndSwapInt32(&s.state, _NORMAL, _DESTROYED) { // signal close idle connections
 		return false
 	}
+	close(s.closing) // signal long-lived handlers to return
 
 	var isTimeout = true
 	s.warnLog(s.listener.Close()) // don't accept connections
@@ -0,0 +1,263 @@
+package zerver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const CONTENTTYPE_EVENTSTREAM = "text/event-stream"
+
+type (
+	// SSEEvent is an event of server-sent events, empty fields are not sent,
+	// multiple-line data is sent as multiple data lines
+	SSEEvent struct {
+		ID    string
+		Event string
+		Data  string
+		Retry time.Duration
+	}
+
+	// SSE is a server-sent events stream
+	SSE struct {
+		// LastEventID is the value of Last-Event-ID header sent by client when
+		// reconnecting, it can be used to resume the stream
+		LastEventID string
+
+		resp      Response
+		heartbeat time.Duration
+		ctx       context.Context
+		cancel    context.CancelFunc
+	}
+
+	// SSEBroadcaster broadcast events to all subscribers, it keep recent events
+	// for subscribers resume from Last-Event-ID
+	SSEBroadcaster struct {
+		// Buffer is the buffered events count of each subscriber, if a subscriber
+		// is full, it will be closed, client will reconnect and resume from history,
+		// default 16
+		Buffer int
+		// History is the count of recent events kept for resuming, default 64
+		History int
+		// Heartbeat is the interval of heartbeat comments, default 15 seconds
+		Heartbeat time.Duration
+
+		lock    sync.Mutex
+		subs    map[chan SSEEvent]struct{}
+		history []SSEEvent
+		id      uint64
+	}
+)
+
+// NewSSE start a server-sent events stream on response, heartbeat comments are
+// sent by Stream with the interval if it's positive. Close must be called when
+// stream is done.
+//
+// The stream is done when client disconnect or server start destroying, it can
+// be detected by Done.
+func NewSSE(req Request, resp Response, heartbeat time.Duration) *SSE {
+	ctx, cancel := context.WithCancel(HTTPRequest(req).Context())
+	go func(closing <-chan struct{}) {
+		select {
+		case <-closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}(req.Server().Closing())
+
+	resp.SetContentType(CONTENTTYPE_EVENTSTREAM, nil)
+	resp.SetHeader(HEADER_CACHECONTROL, "no-cache")
+	resp.SetHeader("X-Accel-Buffering", "no") // disable buffering of nginx
+	resp.ReportOK()
+	resp.Flush()
+
+	return &SSE{
+		LastEventID: req.Header(HEADER_LASTEVENTID),
+		resp:        resp,
+		heartbeat:   heartbeat,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Done return a channel which will be closed when client disconnect or server
+// start destroying
+func (s *SSE) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Close stop the stream
+func (s *SSE) Close() {
+	s.cancel()
+}
+
+// Send send an event and flush it to client
+func (s *SSE) Send(e SSEEvent) error {
+	buf := make([]byte, 0, len(e.Data)+64)
+	if e.ID != "" {
+		buf = appendSSEField(buf, "id", e.ID)
+	}
+	if e.Event != "" {
+		buf = appendSSEField(buf, "event", e.Event)
+	}
+	if e.Retry > 0 {
+		buf = appendSSEField(buf, "retry", strconv.FormatInt(int64(e.Retry/time.Millisecond), 10))
+	}
+	for _, line := range strings.Split(strings.Replace(e.Data, "\r\n", "\n", -1), "\n") {
+		buf = appendSSEField(buf, "data", line)
+	}
+
+	return s.write(append(buf, '\n'))
+}
+
+// Comment send a comment, it's ignored by client
+func (s *SSE) Comment(comment string) error {
+	return s.write(append(appendSSEField(nil, "", comment), '\n'))
+}
+
+func (s *SSE) write(data []byte) error {
+	if _, err := s.resp.Write(data); err != nil {
+		return err
+	}
+	s.resp.Flush()
+
+	return nil
+}
+
+// appendSSEField append "name: value\n", line breaks in value are removed
+func appendSSEField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	buf = append(buf, ':', ' ')
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c != '\n' && c != '\r' {
+			buf = append(buf, c)
+		}
+	}
+
+	return append(buf, '\n')
+}
+
+// Stream send events from channel until the channel is closed, the stream is done,
+// or write failed, heartbeat comments are sent periodically. It return nil if
+// channel closed or stream done.
+func (s *SSE) Stream(events <-chan SSEEvent) error {
+	var heartbeat <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(e); err != nil {
+				return err
+			}
+		case <-heartbeat:
+			if err := s.Comment("heartbeat"); err != nil {
+				return err
+			}
+		case <-s.ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *SSEBroadcaster) init() {
+	if b.subs == nil {
+		b.subs = make(map[chan SSEEvent]struct{})
+		if b.Buffer <= 0 {
+			b.Buffer = 16
+		}
+		if b.History == 0 {
+			b.History = 64
+		}
+		if b.Heartbeat == 0 {
+			b.Heartbeat = 15 * time.Second
+		}
+	}
+}
+
+// Publish send event to all subscribers, if event id is empty, an increasing
+// number is used
+func (b *SSEBroadcaster) Publish(e SSEEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.init()
+
+	b.id++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.id, 10)
+	}
+	if b.History > 0 {
+		if len(b.history) == b.History {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:len(b.history)-1]
+		}
+		b.history = append(b.history, e)
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub <- e:
+		default: // too slow, close it, client will reconnect and resume
+			delete(b.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+// Subscribe subscribe events, events after lastEventID in history are sent first,
+// the returned function must be called to unsubscribe
+func (b *SSEBroadcaster) Subscribe(lastEventID string) (<-chan SSEEvent, func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.init()
+
+	var replay []SSEEvent
+	if lastEventID != "" {
+		for i := range b.history {
+			if b.history[i].ID == lastEventID {
+				replay = b.history[i+1:]
+				break
+			}
+		}
+	}
+
+	sub := make(chan SSEEvent, b.Buffer+len(replay))
+	for _, e := range replay {
+		sub <- e
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub, func() {
+		b.lock.Lock()
+		if _, has := b.subs[sub]; has {
+			delete(b.subs, sub)
+			close(sub)
+		}
+		b.lock.Unlock()
+	}
+}
+
+// Serve stream events to client, it can be used as HandleFunc
+func (b *SSEBroadcaster) Serve(req Request, resp Response) {
+	b.lock.Lock()
+	b.init()
+	heartbeat := b.Heartbeat
+	b.lock.Unlock()
+
+	s := NewSSE(req, resp, heartbeat)
+	defer s.Close()
+
+	events, unsubscribe := b.Subscribe(s.LastEventID)
+	defer unsubscribe()
+
+	s.Stream(events)
+}
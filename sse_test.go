@@ -0,0 +1,77 @@
+package zerver
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+// readSSEBlock read lines until an empty line
+func readSSEBlock(r *bufio.Reader) (string, error) {
+	var block string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return block, err
+		}
+		if line == "\n" {
+			return block, nil
+		}
+		block += line
+	}
+}
+
+func TestSSE(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	s := newTestServer()
+	b := &SSEBroadcaster{Heartbeat: 10 * time.Millisecond}
+	tt.Nil(s.Handle("/events", MapHandler{GET: b.Serve}))
+	tt.Nil(s.Router.Init(s))
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	connect := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequest(GET, ts.URL+"/events", nil)
+		if lastEventID != "" {
+			req.Header.Set(HEADER_LASTEVENTID, lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		tt.Nil(err)
+		tt.Eq(CONTENTTYPE_EVENTSTREAM, resp.Header.Get(HEADER_CONTENTTYPE))
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	b.Publish(SSEEvent{Event: "start", Data: "0"})
+
+	resp, r := connect("")
+	block, err := readSSEBlock(r) // stream is started and subscribed
+	tt.Nil(err)
+	tt.Eq(": heartbeat\n", block)
+
+	b.Publish(SSEEvent{Data: "a\nb", Retry: time.Second})
+	for block == ": heartbeat\n" {
+		block, err = readSSEBlock(r)
+		tt.Nil(err)
+	}
+	tt.Eq("id: 2\nretry: 1000\ndata: a\ndata: b\n", block)
+	resp.Body.Close()
+
+	resp, r = connect("1") // resume
+	defer resp.Body.Close()
+	block, err = readSSEBlock(r)
+	tt.Nil(err)
+	tt.Eq("id: 2\nretry: 1000\ndata: a\ndata: b\n", block)
+
+	close(s.closing) // server destroying
+	for err == nil {
+		_, err = readSSEBlock(r)
+	}
+	tt.Eq(io.EOF, err)
+}
@@ -12,6 +12,8 @@ type (
 	URLVarIndexer interface {
 		// Pattern return original pattern of handler
 		Pattern() string
+		// RouteMeta return metadata of matched route, nil if there is no metadata
+		RouteMeta() RouteMeta
 		// URLVar return value of variable
 		URLVar(name string) string
 		URLVarDef(name string, defvalue string) string
@@ -22,13 +24,20 @@ type (
 	// urlVarIndexer is an implementation of URLVarIndexer
 	urlVarIndexer struct {
 		pattern string
+		meta    RouteMeta
 		vars    map[string]int // url variables and indexs of sections splited by '/'
 		values  []string       // all url variable values
+		static  bool           // shared by static route, never recycled
 	}
 )
 
 func (v *urlVarIndexer) destroySelf() {
+	if v.static {
+		return
+	}
+
 	v.pattern = ""
+	v.meta = nil
 	v.values = v.values[:0]
 	v.vars = nil
 	recycleVarIndexer(v)
@@ -38,6 +47,10 @@ func (v *urlVarIndexer) Pattern() string {
 	return v.pattern
 }
 
+func (v *urlVarIndexer) RouteMeta() RouteMeta {
+	return v.meta
+}
+
 // URLVar return values of variable
 func (v *urlVarIndexer) URLVar(name string) string {
 	if index, has := v.vars[name]; has {
@@ -0,0 +1,413 @@
+package zerver
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// builtin validate rules
+	RULE_REQUIRED = "required"
+	RULE_MIN      = "min"
+	RULE_MAX      = "max"
+	RULE_LEN      = "len"
+	RULE_EMAIL    = "email"
+	RULE_URL      = "url"
+	RULE_ONEOF    = "oneof"
+)
+
+type (
+	// ValidateRule check whether value is valid with the rule parameter, value is
+	// never a pointer and never zero value
+	ValidateRule func(v reflect.Value, param string) bool
+
+	// ValidationError is a violation of a field
+	ValidationError struct {
+		Field  string `json:"field"`
+		Rule   string `json:"rule"`
+		Param  string `json:"param,omitempty"`
+		Reason string `json:"reason"`
+	}
+
+	// ValidationErrors is all violations of a value, it can be sent to client
+	// directly, or through ReportInvalid
+	ValidationErrors []ValidationError
+
+	validateRule struct {
+		rule    ValidateRule
+		message string
+	}
+
+	fieldRule struct {
+		name  string
+		param string
+		rule  ValidateRule
+	}
+
+	// validateField is a field should be validated
+	validateField struct {
+		index    []int
+		name     string // json name if exist
+		required bool
+		rules    []fieldRule
+		nested   bool // struct, or slice of struct
+	}
+)
+
+var (
+	// Translator translate validation message of key "validate."+rule to the
+	// locale, the message can contains "{field}" and "{param}", if it return
+	// empty string, default message is used. i18n.I18N can be used here.
+	Translator func(locale, key string) string
+
+	validateRules = map[string]validateRule{
+		RULE_REQUIRED: {nil, "{field} is required"},
+		RULE_MIN:      {validateMin, "{field} must be at least {param}"},
+		RULE_MAX:      {validateMax, "{field} must be at most {param}"},
+		RULE_LEN:      {validateLen, "{field} must have length {param}"},
+		RULE_EMAIL:    {validateEmail, "{field} must be a valid email address"},
+		RULE_URL:      {validateURL, "{field} must be a valid url"},
+		RULE_ONEOF:    {validateOneOf, "{field} must be one of {param}"},
+	}
+	validateRulesLock sync.RWMutex
+
+	validateFieldsCache = make(map[reflect.Type][]validateField)
+	validateFieldsLock  sync.RWMutex
+
+	emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// RegisterValidateRule register a custom validate rule, message is used to
+// report violation, it can contains "{field}" and "{param}". Rules should be
+// registered before any validation.
+func RegisterValidateRule(name string, rule ValidateRule, message string) {
+	validateRulesLock.Lock()
+	defer validateRulesLock.Unlock()
+
+	if _, has := validateRules[name]; has {
+		log.Panicln("Validate rule already exist:", name)
+	}
+	if rule == nil {
+		log.Panicln("Validate rule shouldn't be nil:", name)
+	}
+	validateRules[name] = validateRule{rule, message}
+}
+
+func (e ValidationError) Error() string {
+	return e.Reason
+}
+
+func (es ValidationErrors) Error() string {
+	s := make([]string, len(es))
+	for i := range es {
+		s[i] = es[i].Error()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// Translate translate reasons to the locale by Translator, if Translator is
+// nil, errors is returned directly
+func (es ValidationErrors) Translate(locale string) ValidationErrors {
+	if Translator == nil {
+		return es
+	}
+
+	errs := make(ValidationErrors, len(es))
+	for i, e := range es {
+		if msg := Translator(locale, "validate."+e.Rule); msg != "" {
+			e.Reason = e.message(msg)
+		}
+		errs[i] = e
+	}
+
+	return errs
+}
+
+func (e ValidationError) message(msg string) string {
+	return strings.NewReplacer("{field}", e.Field, "{param}", e.Param).Replace(msg)
+}
+
+// Validate validate struct value by tag "validate", such as
+// `validate:"required,min=3,max=20"`, "oneof" parameter is separated by space,
+// rules except "required" are skipped for zero value. Struct fields, and slice of
+// structs are validated recursively even if they are zero, nil pointers are skipped,
+// error field name is the json name.
+//
+// All violations are returned as ValidationErrors, non-struct value is always valid.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if errs := validateStruct(rv, "", nil); len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs ValidationErrors) ValidationErrors {
+	for _, f := range structValidateFields(v.Type()) {
+		errs = f.validate(v.FieldByIndex(f.index), prefix+f.name, errs)
+	}
+
+	return errs
+}
+
+func (f *validateField) validate(v reflect.Value, name string, errs ValidationErrors) ValidationErrors {
+	if isZeroValue(v) {
+		if f.required {
+			return append(errs, newValidationError(name, RULE_REQUIRED, ""))
+		}
+		// zero value of nested struct is still validated, only nil pointer is skipped
+		if f.nested && v.Kind() == reflect.Struct {
+			return validateStruct(v, name+".", errs)
+		}
+
+		return errs
+	}
+
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	for _, r := range f.rules {
+		if !r.rule(v, r.param) {
+			return append(errs, newValidationError(name, r.name, r.param))
+		}
+	}
+
+	if f.nested {
+		if v.Kind() == reflect.Struct {
+			return validateStruct(v, name+".", errs)
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.Struct {
+				errs = validateStruct(elem, name+"["+strconv.Itoa(i)+"].", errs)
+			}
+		}
+	}
+
+	return errs
+}
+
+func newValidationError(field, rule, param string) ValidationError {
+	e := ValidationError{
+		Field: field,
+		Rule:  rule,
+		Param: param,
+	}
+	validateRulesLock.RLock()
+	e.Reason = e.message(validateRules[rule].message)
+	validateRulesLock.RUnlock()
+
+	return e
+}
+
+// structValidateFields parse validate fields of struct type, fields of embedded
+// struct are included
+func structValidateFields(t reflect.Type) []validateField {
+	validateFieldsLock.RLock()
+	fields, has := validateFieldsCache[t]
+	validateFieldsLock.RUnlock()
+	if has {
+		return fields
+	}
+
+	fields = parseValidateFields(t, nil, fields)
+	validateFieldsLock.Lock()
+	validateFieldsCache[t] = fields
+	validateFieldsLock.Unlock()
+
+	return fields
+}
+
+func parseValidateFields(t reflect.Type, index []int, fields []validateField) []validateField {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := make([]int, len(index)+1)
+		copy(fi, index)
+		fi[len(index)] = i
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = parseValidateFields(f.Type, fi, fields)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+
+		field := validateField{
+			index:  fi,
+			name:   f.Name,
+			nested: isNestedType(f.Type),
+		}
+		if name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]; name != "" && name != "-" {
+			field.name = name
+		}
+
+		if tag := f.Tag.Get("validate"); tag != "" && tag != "-" {
+			validateRulesLock.RLock()
+			for _, r := range strings.Split(tag, ",") {
+				name, param := r, ""
+				if i := strings.IndexByte(r, '='); i >= 0 {
+					name, param = r[:i], r[i+1:]
+				}
+				if name == RULE_REQUIRED {
+					field.required = true
+					continue
+				}
+
+				rule, has := validateRules[name]
+				if !has {
+					validateRulesLock.RUnlock()
+					log.Panicln("Unknown validate rule:", name, "of field", t.Name()+"."+f.Name)
+				}
+				field.rules = append(field.rules, fieldRule{name, param, rule.rule})
+			}
+			validateRulesLock.RUnlock()
+		}
+
+		if field.required || len(field.rules) != 0 || field.nested {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// isNestedType check whether type is a struct, or a slice of struct, pointers are
+// dereferenced, time.Time is not treated as nested
+func isNestedType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return v.IsZero()
+}
+
+// sizeOf return number value, or length of string(in characters), slice and map
+func sizeOf(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+
+	return 0, false
+}
+
+func compareSize(v reflect.Value, param string, cmp func(size, limit float64) bool) bool {
+	size, ok := sizeOf(v)
+	if !ok {
+		return false
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+
+	return err == nil && cmp(size, limit)
+}
+
+func validateMin(v reflect.Value, param string) bool {
+	return compareSize(v, param, func(size, limit float64) bool { return size >= limit })
+}
+
+func validateMax(v reflect.Value, param string) bool {
+	return compareSize(v, param, func(size, limit float64) bool { return size <= limit })
+}
+
+func validateLen(v reflect.Value, param string) bool {
+	return compareSize(v, param, func(size, limit float64) bool { return size == limit })
+}
+
+func validateEmail(v reflect.Value, _ string) bool {
+	return v.Kind() == reflect.String && emailRegexp.MatchString(v.String())
+}
+
+func validateURL(v reflect.Value, _ string) bool {
+	if v.Kind() != reflect.String {
+		return false
+	}
+	u, err := url.ParseRequestURI(v.String())
+
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func validateOneOf(v reflect.Value, param string) bool {
+	s := fmt.Sprint(v.Interface())
+	for _, p := range strings.Fields(param) {
+		if s == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReportInvalid report error returned by Request.Receive or Request.Bind to client
+// as problem details like ReportError, status is mapped by MapError: ValidationErrors
+// is reported as 422 and translated by the first language of Accept-Language header,
+// BindErrors as 400, body size errors as 413, unsupported content type as 415.
+// Errors which are not recognized are reported as 400, each item of "errors" has at
+// least "field" and "reason".
+func ReportInvalid(req Request, resp Response, err error) error {
+	p := MapError(req, err)
+	if p == nil {
+		p = NewProblem(http.StatusBadRequest, "")
+		p.Errors = BindErrors{{Source: BIND_BODY, Reason: err.Error()}}
+	}
+
+	return sendProblem(resp, p)
+}
+
+func acceptLanguage(req Request) string {
+	lang := req.Header(HEADER_ACCEPTLANGUAGE)
+	if i := strings.IndexAny(lang, ",;"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	return strings.TrimSpace(lang)
+}
@@ -0,0 +1,132 @@
+package zerver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cosiner/gohper/testing2"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testUser struct {
+	Name      string         `json:"name" validate:"required,min=3,max=5"`
+	Email     string         `json:"email" validate:"email"`
+	Age       int            `json:"age" validate:"min=18"`
+	Role      string         `json:"role,omitempty" validate:"oneof=admin user"`
+	Code      string         `json:"code" validate:"even"`
+	Address   *testAddress   `json:"address"`
+	Addresses []*testAddress `json:"addresses"`
+}
+
+type testProfile struct {
+	Home testAddress  `json:"home"`
+	Work *testAddress `json:"work"`
+}
+
+func init() {
+	RegisterValidateRule("even", func(v reflect.Value, _ string) bool {
+		return len(v.String())%2 == 0
+	}, "{field} must have even length")
+}
+
+func TestValidate(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	tt.Nil(Validate(&testUser{Name: "abc", Age: 20, Role: "user"}))
+	tt.Nil(Validate(1))
+
+	err := Validate(&testUser{
+		Name:      "abcdef",
+		Email:     "abc",
+		Age:       10,
+		Role:      "guest",
+		Code:      "a",
+		Address:   &testAddress{},
+		Addresses: []*testAddress{{City: "x"}, {}},
+	})
+	errs, is := err.(ValidationErrors)
+	tt.True(is)
+
+	var fields, rules []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+		rules = append(rules, e.Rule)
+	}
+	tt.DeepEq([]string{"name", "email", "age", "role", "code", "address.city", "addresses[1].city"}, fields)
+	tt.DeepEq([]string{"max", "email", "min", "oneof", "even", "required", "required"}, rules)
+	tt.Eq("name must be at most 5", errs[0].Reason)
+	tt.Eq("code must have even length", errs[4].Reason)
+
+	errs = Validate(&testUser{}).(ValidationErrors)
+	tt.Eq(1, len(errs))
+	tt.Eq("name is required", errs[0].Reason)
+
+	// zero nested struct is validated, nil pointer is skipped
+	errs = Validate(&testProfile{}).(ValidationErrors)
+	tt.Eq(1, len(errs))
+	tt.Eq("home.city", errs[0].Field)
+	tt.Eq(RULE_REQUIRED, errs[0].Rule)
+	tt.Nil(Validate(&testProfile{Home: testAddress{City: "x"}}))
+}
+
+func TestReportInvalid(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	Translator = func(locale, key string) string {
+		if locale == "zh-CN" && key == "validate.required" {
+			return "{field} 不能为空"
+		}
+		return ""
+	}
+	defer func() { Translator = nil }()
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/users", MapHandler{
+		POST: func(req Request, resp Response) {
+			var u testUser
+			if err := req.Receive(&u); err != nil {
+				ReportInvalid(req, resp, err)
+			}
+		},
+	}))
+	tt.Nil(s.Router.Init(s))
+
+	serve := func(body string) (int, []map[string]string) {
+		header := http.Header{HEADER_ACCEPTLANGUAGE: {"zh-CN,zh;q=0.8"}}
+		w, buf := serveTest(s, POST, "/users", header, strings.NewReader(body))
+
+		var doc struct {
+			Status int
+			Errors []map[string]string
+		}
+		if json.Unmarshal(buf.Bytes(), &doc) == nil {
+			tt.Eq(w.Status, doc.Status)
+			tt.Eq(CONTENTTYPE_PROBLEM, w.Headers.Get(HEADER_CONTENTTYPE))
+		}
+		return w.Status, doc.Errors
+	}
+
+	status, errs := serve(`{"age":20}`)
+	tt.Eq(http.StatusUnprocessableEntity, status)
+	tt.Eq(1, len(errs))
+	tt.Eq("name", errs[0]["field"])
+	tt.Eq("name 不能为空", errs[0]["reason"])
+
+	status, errs = serve(`{`)
+	tt.Eq(http.StatusBadRequest, status)
+	tt.Eq(1, len(errs))
+	tt.Eq(BIND_BODY, errs[0]["source"])
+
+	status, errs = serve(`{"name":"abc","age":"20"}`)
+	tt.Eq(http.StatusBadRequest, status)
+	tt.Eq(1, len(errs))
+
+	status, _ = serve(`{"name":"abc","age":20}`)
+	tt.Eq(http.StatusOK, status)
+}
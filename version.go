@@ -0,0 +1,203 @@
+package zerver
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosiner/gohper/errors"
+)
+
+const (
+	ErrVersionExist = errors.Err("version already exist")
+)
+
+type (
+	// VersionResolver resolve api version from request, if there is no version,
+	// empty string should be returned
+	VersionResolver func(Request) string
+
+	// Version is a version of api
+	Version struct {
+		Name string
+		// Handler can be any type can be converted to Handler
+		Handler interface{}
+		// Deprecation is the time this version is deprecated, if it's not zero,
+		// "Deprecation" header will be set
+		Deprecation time.Time
+		// Sunset is the time this version will be removed, if it's not zero,
+		// "Sunset" header will be set
+		Sunset time.Time
+
+		handler     Handler
+		deprecation string
+		sunset      string
+	}
+
+	// VersionHandler dispatch request to handler of the version resolved from request,
+	// resolvers are tried in order, the first non-empty version is used, if there is no
+	// version in request, default version is used. If the version is not exist,
+	// 406(not acceptable) is reported.
+	VersionHandler struct {
+		Default   string
+		Resolvers []VersionResolver
+
+		versions []*Version
+	}
+)
+
+// VersionFromMediaType resolve version from parameter of "Accept" header such as
+// application/vnd.x+json;version=2
+func VersionFromMediaType(param string) VersionResolver {
+	return func(req Request) string {
+		for _, typ := range strings.Split(req.Accepts(), ",") {
+			params := strings.Split(typ, ";")
+			for _, p := range params[1:] {
+				name, value := p, ""
+				if i := strings.IndexByte(p, '='); i >= 0 {
+					name, value = p[:i], p[i+1:]
+				}
+
+				if strings.EqualFold(strings.TrimSpace(name), param) {
+					return normalizeVersion(strings.Trim(strings.TrimSpace(value), `"`))
+				}
+			}
+		}
+
+		return ""
+	}
+}
+
+// VersionFromHeader resolve version from header such as X-API-Version: 2
+func VersionFromHeader(name string) VersionResolver {
+	return func(req Request) string {
+		return normalizeVersion(strings.TrimSpace(req.Header(name)))
+	}
+}
+
+// VersionFromURLVar resolve version from url variable, for pattern "/:version/user",
+// path "/v2/user" has version "2"
+func VersionFromURLVar(name string) VersionResolver {
+	return func(req Request) string {
+		return normalizeVersion(req.URLVar(name))
+	}
+}
+
+// normalizeVersion remove the "v" prefix of version
+func normalizeVersion(v string) string {
+	if len(v) > 1 && (v[0] == 'v' || v[0] == 'V') {
+		return v[1:]
+	}
+
+	return v
+}
+
+// AddVersion add a version, version name should not be duplicate
+func (vh *VersionHandler) AddVersion(v Version) error {
+	if vh.version(v.Name) != nil {
+		return ErrVersionExist
+	}
+
+	if v.handler = convertHandler(v.Handler); v.handler == nil {
+		log.Panicln("Not a Handler: version " + v.Name)
+	}
+	if !v.Deprecation.IsZero() {
+		v.deprecation = "@" + strconv.FormatInt(v.Deprecation.Unix(), 10)
+	}
+	if !v.Sunset.IsZero() {
+		v.sunset = v.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	vh.versions = append(vh.versions, &v)
+
+	return nil
+}
+
+func (vh *VersionHandler) version(name string) *Version {
+	for _, v := range vh.versions {
+		if v.Name == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (vh *VersionHandler) Init(env Environment) error {
+	if vh.Default != "" && vh.version(vh.Default) == nil {
+		return errors.Err("default version " + vh.Default + " is not exist")
+	}
+
+	var err error
+	for i := 0; i < len(vh.versions) && err == nil; i++ {
+		err = vh.versions[i].handler.Init(env)
+	}
+
+	return err
+}
+
+func (vh *VersionHandler) Destroy() {
+	for _, v := range vh.versions {
+		v.handler.Destroy()
+	}
+}
+
+// Handler return nil if there is no version can process this method
+func (vh *VersionHandler) Handler(method string) HandleFunc {
+	for _, v := range vh.versions {
+		if v.handler.Handler(method) != nil {
+			return vh.handle
+		}
+	}
+
+	return nil
+}
+
+func (vh *VersionHandler) handle(req Request, resp Response) {
+	var name string
+	for i := 0; i < len(vh.Resolvers) && name == ""; i++ {
+		name = vh.Resolvers[i](req)
+	}
+	if name == "" {
+		name = vh.Default
+	}
+
+	v := vh.version(name)
+	if v == nil {
+		resp.ReportNotAcceptable()
+		return
+	}
+
+	handle := v.handler.Handler(req.Method())
+	if handle == nil {
+		resp.ReportMethodNotAllowed()
+		return
+	}
+
+	if v.deprecation != "" {
+		resp.SetHeader(HEADER_DEPRECATION, v.deprecation)
+	}
+	if v.sunset != "" {
+		resp.SetHeader(HEADER_SUNSET, v.sunset)
+	}
+
+	handle(req, resp)
+}
+
+// DescribeRoute list all versions
+func (vh *VersionHandler) DescribeRoute() string {
+	names := make([]string, len(vh.versions))
+	for i, v := range vh.versions {
+		names[i] = v.Name
+		if v.Name == vh.Default {
+			names[i] += "(default)"
+		}
+		if v.deprecation != "" {
+			names[i] += "(deprecated)"
+		}
+	}
+
+	return "versions: " + strings.Join(names, ",")
+}
@@ -0,0 +1,84 @@
+package zerver
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cosiner/gohper/testing2"
+	"github.com/cosiner/ygo/resource"
+)
+
+func TestVersionHandler(t *testing.T) {
+	tt := testing2.Wrap(t)
+
+	var version string
+	handler := func(v string) HandleFunc {
+		return func(Request, Response) {
+			version = v
+		}
+	}
+
+	vh := &VersionHandler{
+		Default: "2",
+		Resolvers: []VersionResolver{
+			VersionFromMediaType("version"),
+			VersionFromHeader("X-API-Version"),
+		},
+	}
+	tt.Nil(vh.AddVersion(Version{
+		Name:        "1",
+		Handler:     MapHandler{GET: handler("1")},
+		Deprecation: time.Now(),
+	}))
+	tt.Nil(vh.AddVersion(Version{
+		Name:    "2",
+		Handler: MapHandler{GET: handler("2"), POST: handler("2")},
+	}))
+	tt.True(vh.AddVersion(Version{Name: "2", Handler: MapHandler{}}) == ErrVersionExist)
+
+	s := newTestServer()
+	tt.Nil(s.Handle("/user", vh))
+	tt.Nil(vh.Init(s))
+
+	serve := func(method string, header http.Header) *MockWriter {
+		version = ""
+		w, _ := serveTest(s, method, "/user", header, nil)
+		return w
+	}
+
+	serve(GET, http.Header{})
+	tt.Eq("2", version)
+
+	w := serve(GET, http.Header{HEADER_ACCEPT: {"application/json;version=1"}})
+	tt.Eq("1", version)
+	tt.True(w.Headers.Get(HEADER_DEPRECATION) != "")
+
+	// vendor type is negotiated by structured syntax suffix
+	w = serve(GET, http.Header{HEADER_ACCEPT: {"application/vnd.x+json;version=1"}})
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq("1", version)
+	tt.Eq(resource.RES_JSON, w.Headers.Get(HEADER_CONTENTTYPE))
+
+	w = serve(GET, http.Header{HEADER_ACCEPT: {`text/html, application/vnd.x+json; version="v2"`}})
+	tt.Eq(http.StatusOK, w.Status)
+	tt.Eq("2", version)
+
+	w = serve(GET, http.Header{HEADER_ACCEPT: {"application/vnd.x+yaml;version=1"}})
+	tt.Eq(http.StatusNotAcceptable, w.Status)
+	tt.Eq("", version)
+
+	serve(GET, http.Header{"X-Api-Version": {"v1"}})
+	tt.Eq("1", version)
+
+	w = serve(POST, http.Header{"X-Api-Version": {"1"}})
+	tt.Eq(http.StatusMethodNotAllowed, w.Status)
+
+	w = serve(GET, http.Header{"X-Api-Version": {"3"}})
+	tt.Eq(http.StatusNotAcceptable, w.Status)
+
+	w = serve(DELETE, http.Header{})
+	tt.Eq(http.StatusMethodNotAllowed, w.Status)
+
+	tt.Eq("versions: 1(deprecated),2(default)", vh.DescribeRoute())
+}